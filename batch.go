@@ -0,0 +1,357 @@
+package cache
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// GetMulti reads the values for the given keys into `out` which must be
+// a pointer to a map keyed by string. Each storage is asked for all the
+// keys still missing in a single call if it implements BatchStorage.
+// Keys found in none of the storage are left out of `out`. Expired values
+// are served while they may be revalidated like by Get. Values found
+// in a lower storage are propagated into the high priority storage
+// missing them in batch too
+func (c *Cache) GetMulti(keys []string, out interface{}) error {
+	return c.getMulti(context.Background(), keys, decoder(out))
+}
+
+// GetMultiContext is like GetMulti but carries ctx down to the storage
+func (c *Cache) GetMultiContext(ctx context.Context, keys []string, out interface{}) error {
+	return c.getMulti(ctx, keys, decoder(out))
+}
+func (c *Cache) getMulti(ctx context.Context, keys []string, decode func(v interface{}) error) error {
+	type miss struct {
+		s    Storage
+		keys []string
+	}
+
+	var (
+		pending = unique(keys)
+		found   = make(map[string]*item, len(pending))
+		from    = make(map[string]Storage, len(pending))
+		misses  []miss
+	)
+	step := func(high bool) func(s Storage) (bool, error) {
+		return func(s Storage) (bool, error) {
+			items, err := c.readMulti(ctx, s, pending...)
+			if err != nil {
+				return false, err
+			}
+
+			var missing []string
+			for _, key := range pending {
+				if it, ok := items[key]; ok {
+					found[key] = it
+					from[key] = s
+				} else {
+					missing = append(missing, key)
+				}
+			}
+			if high && len(missing) > 0 {
+				misses = append(misses, miss{s: s, keys: missing})
+			}
+			pending = missing
+			return len(pending) == 0, nil
+		}
+	}
+	err := c.LoopContext(ctx, step(true), step(false))
+
+	for _, m := range misses {
+		items := make(map[string]*item, len(m.keys))
+		for _, key := range m.keys {
+			if it, ok := found[key]; ok && !it.raw {
+				items[key] = it
+			}
+		}
+		c.propagateMulti(ctx, m.s, from, items)
+	}
+
+	values := make(map[string]interface{}, len(found))
+	for key, it := range found {
+		values[key] = it.Val
+	}
+	if errDecode := decode(values); errDecode != nil {
+		return errDecode
+	}
+	return err
+}
+
+func (c *Cache) readMulti(ctx context.Context, s Storage, keys ...string) (items map[string]*item, err error) {
+	nsKeys := make([]string, len(keys))
+	for i := range keys {
+		nsKeys[i] = c.NsKey(keys[i])
+	}
+
+	start := time.Now()
+	values, err := readMulti(ctx, s, nsKeys...)
+	took := time.Since(start)
+	if err != nil {
+		c.observe(s, OpRead, took, len(keys), 0, 0, err)
+		return nil, err
+	}
+	var size int
+	for _, v := range values {
+		size += sizeOf(v)
+	}
+	defer func() {
+		c.observe(s, OpRead, took, len(keys), len(items), size, err)
+	}()
+
+	items = make(map[string]*item, len(values))
+	for i, key := range keys {
+		v, ok := values[nsKeys[i]]
+		if !ok {
+			continue
+		}
+		it, err := c.item(v)
+		if err != nil {
+			return nil, err
+		}
+		if c.reap(ctx, s, key, &it) || !it.servable() {
+			continue
+		}
+		items[key] = &it
+	}
+
+	var tagged []*item
+	for _, it := range items {
+		if len(it.Tags) > 0 {
+			tagged = append(tagged, it)
+		}
+	}
+	if len(tagged) == 0 {
+		return items, nil
+	}
+	versions, err := c.versions(ctx, s, tagsOf(tagged...))
+	if err != nil {
+		return nil, err
+	}
+	for key, it := range items {
+		if it.outdated(versions) {
+			delete(items, key)
+		}
+	}
+	return items, nil
+}
+
+// propagateMulti writes the given items into s in a single batch and
+// copies their tags over from the storage they have been read from
+func (c *Cache) propagateMulti(ctx context.Context, s Storage, from map[string]Storage, items map[string]*item) (err error) {
+	if len(items) == 0 {
+		return nil
+	}
+	start := time.Now()
+	defer func() {
+		keys := make([]string, 0, len(items))
+		for key := range items {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		c.observe(s, OpPropagate, time.Since(start), len(items), 0, 0, err)
+		c.logPropagation(s, nil, keys, err)
+	}()
+
+	all := make([]*item, 0, len(items))
+	for _, it := range items {
+		all = append(all, it)
+	}
+	versions, err := c.versions(ctx, s, tagsOf(all...))
+	if err != nil {
+		return err
+	}
+
+	var (
+		values  = make(map[string]interface{}, len(items))
+		ttl     time.Duration
+		forever bool
+	)
+	for key, it := range items {
+		v, err := c.value(s, it.withVersions(versions))
+		if err != nil {
+			return err
+		}
+		values[c.NsKey(key)] = v
+
+		// items keep their own expiration, so the batch is written
+		// with the longest one of them
+		if it.Expires == 0 {
+			forever = true
+		} else if left := it.ttl(); left > ttl {
+			ttl = left
+		}
+	}
+	if forever {
+		ttl = 0
+	}
+	if err := c.writeMulti(ctx, s, values, ttl); err != nil {
+		return err
+	}
+
+	for key := range items {
+		tags, err := c.tagger.Tags(withContext(ctx, from[key]), key)
+		if err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			continue
+		}
+		if err := c.tagger.Tag(withContext(ctx, s), key, tags...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetMulti stores all the given values the same way Set does using a
+// single call per storage implementing BatchStorage
+func (c *Cache) SetMulti(values map[string]interface{}, expiration time.Duration, tags ...string) error {
+	return c.setMulti(context.Background(), values, expiration, tags...)
+}
+
+// SetMultiContext is like SetMulti but carries ctx down to the storage
+func (c *Cache) SetMultiContext(ctx context.Context, values map[string]interface{}, expiration time.Duration, tags ...string) error {
+	return c.setMulti(ctx, values, expiration, tags...)
+}
+func (c *Cache) setMulti(ctx context.Context, values map[string]interface{}, expiration time.Duration, tags ...string) error {
+	var (
+		items   = make(map[string]interface{}, len(values))
+		ttl     time.Duration
+		encoded map[string]interface{}
+	)
+	for key, v := range values {
+		it := c.newItem(key, v, expiration)
+		items[c.NsKey(key)] = it
+		ttl = it.ttl()
+	}
+
+	return c.LoopContext(
+		ctx,
+		func(s Storage) (bool, error) {
+			versions, err := c.versions(ctx, s, tags)
+			if err != nil {
+				return false, err
+			}
+
+			batch := items
+			if versions != nil {
+				// items record the versions of the tags in each storage
+				batch = make(map[string]interface{}, len(items))
+				for key, it := range items {
+					it := it.(item)
+					it.Tags = versions
+					if batch[key], err = c.value(s, it); err != nil {
+						return false, err
+					}
+				}
+			} else if _, ok := s.(ByteStorage); ok {
+				// encode once for all the byte storage
+				if encoded == nil {
+					encoded = make(map[string]interface{}, len(items))
+					for key, it := range items {
+						v, err := encodeItem(c.enc, it.(item))
+						if err != nil {
+							return false, err
+						}
+						encoded[key] = v
+					}
+				}
+				batch = encoded
+			}
+			if err := c.writeMulti(ctx, s, batch, ttl); err != nil {
+				return false, err
+			}
+			if len(tags) == 0 {
+				return false, nil
+			}
+			for key := range values {
+				if err := c.tagger.Tag(withContext(ctx, s), key, tags...); err != nil {
+					return false, err
+				}
+			}
+			return false, nil
+		},
+		nil,
+	)
+}
+
+// writeMulti writes the given values like writeMulti and records the call
+func (c *Cache) writeMulti(ctx context.Context, s Storage, values map[string]interface{}, ttl time.Duration) error {
+	var size int
+	for _, v := range values {
+		size += sizeOf(v)
+	}
+
+	start := time.Now()
+	err := writeMulti(ctx, s, values, ttl)
+	c.observe(s, OpWrite, time.Since(start), len(values), 0, size, err)
+	return err
+}
+
+// readMulti reads the given keys in a single call if the storage
+// implements BatchStorage or one by one otherwise
+func readMulti(ctx context.Context, s Storage, keys ...string) (map[string]interface{}, error) {
+	if bs, ok := s.(BatchStorage); ok {
+		return bs.ReadMulti(ctx, keys...)
+	}
+
+	values := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		v, err := read(ctx, s, key)
+		if err == ErrKeyNotExist {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[key] = v
+	}
+	return values, nil
+}
+
+// writeMulti writes the given values in a single call if the storage
+// implements BatchStorage or one by one otherwise
+func writeMulti(ctx context.Context, s Storage, values map[string]interface{}, ttl time.Duration) error {
+	if bs, ok := s.(BatchStorage); ok {
+		return bs.WriteMulti(ctx, values, ttl)
+	}
+
+	for key, v := range values {
+		if err := write(ctx, s, key, v, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeMulti deletes the given keys in a single call if the storage
+// implements BatchStorage or one by one otherwise
+func removeMulti(ctx context.Context, s Storage, keys ...string) error {
+	if bs, ok := s.(BatchStorage); ok {
+		return bs.DeleteMulti(ctx, keys...)
+	}
+
+	for _, key := range keys {
+		if err := remove(ctx, s, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unique(keys []string) []string {
+	var (
+		seen = make(map[string]struct{}, len(keys))
+		uniq = make([]string, 0, len(keys))
+	)
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		uniq = append(uniq, key)
+	}
+	return uniq
+}
@@ -1,15 +1,21 @@
 package cache
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"reflect"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
-	"github.com/mitchellh/mapstructure"
-	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
 )
 
 const (
@@ -31,31 +37,69 @@ var (
 // Cache manages to Set, Get, Delet and Tag keys
 type Cache struct {
 	storage map[Priority][]Storage
-	logger  *logrus.Logger
+	logger  Logger
+	level   *slog.LevelVar
 	tagger  Tagger
+	enc     encoding
 	ns      string
+	loads   singleflight.Group
+
+	beta                 float64
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+	revalidating         sync.Map
+
+	tiers   []tier
+	metrics []Metrics
+
+	tracer       trace.Tracer
+	hashSpanKeys bool
+
+	hooks hooks
 }
 
 // New constructs a new Cache instance which can store, read
 // and remove items with tags
 func New(options ...Option) *Cache {
 	c := &Cache{
-		logger:  logrus.New(),
+		level:   new(slog.LevelVar),
 		storage: make(map[Priority][]Storage),
 	}
+	c.logger = newLogger(c.level)
 	options = append(
 		[]Option{
 			WithTagger(newStdTagger(c.logger, "go:cache:tagger")),
 			WithNamespace("go:cache"),
+			WithCodec(JSON),
 		},
 		options...,
 	)
 	for i := range options {
 		options[i](c)
 	}
+	if ls, ok := c.tagger.(loggerSetter); ok {
+		c.tagger = ls.withLogger(c.logger)
+	}
+	for priority, storage := range c.storage {
+		// storage may be the slice the caller has passed to WithStorage
+		storage = append([]Storage(nil), storage...)
+		for i, s := range storage {
+			if n, ok := s.(namespacer); ok {
+				storage[i] = n.withNamespace(c.ns)
+			}
+		}
+		c.storage[priority] = storage
+	}
+	c.tiers = tiers(c.storage)
 	return c
 }
 
+// namespacer is implemented by storage scoping Flush to a namespace, which
+// are given the namespace of the cache they're used with
+type namespacer interface {
+	withNamespace(ns string) Storage
+}
+
 // NsKey wraps the given key with the namespace prefix
 func (c *Cache) NsKey(key string) string {
 	return fmt.Sprintf("%s:%s", c.ns, key)
@@ -64,13 +108,35 @@ func (c *Cache) NsKey(key string) string {
 // Loop iterates through registered high and medium storage and pass them to the
 // coressponding function to use
 func (c *Cache) Loop(high func(s Storage) (bool, error), medium func(s Storage) (bool, error)) (e error) {
+	return c.LoopContext(context.Background(), high, medium)
+}
+
+// LoopContext is like Loop but stops iterating as soon as the given context
+// is done. The context error is reported along with high priority errors
+func (c *Cache) LoopContext(ctx context.Context, high func(s Storage) (bool, error), medium func(s Storage) (bool, error)) (e error) {
+	if medium == nil {
+		medium = high
+	}
+	return c.loop(
+		ctx,
+		func(_ context.Context, s Storage) (bool, error) { return high(s) },
+		func(_ context.Context, s Storage) (bool, error) { return medium(s) },
+	)
+}
+
+// loop is like LoopContext but passes a context to the functions, which
+// carries the span of the storage visited if tracing is configured
+func (c *Cache) loop(ctx context.Context, high func(ctx context.Context, s Storage) (bool, error), medium func(ctx context.Context, s Storage) (bool, error)) (e error) {
 	if medium == nil {
 		medium = high
 	}
 
 	highPriority, _ := c.storage[PriorityHigh]
 	for _, storage := range highPriority {
-		if terminate, err := high(storage); err != nil {
+		if err := ctx.Err(); err != nil {
+			return multierror.Append(e, err)
+		}
+		if terminate, err := c.visit(ctx, PriorityHigh, storage, high); err != nil {
 			e = multierror.Append(e, err)
 		} else if terminate {
 			return nil
@@ -79,7 +145,13 @@ func (c *Cache) Loop(high func(s Storage) (bool, error), medium func(s Storage)
 
 	mediumPriority, _ := c.storage[PriorityMedium]
 	for _, storage := range mediumPriority {
-		terminate, _ := medium(storage)
+		if err := ctx.Err(); err != nil {
+			return multierror.Append(e, err)
+		}
+		terminate, err := c.visit(ctx, PriorityMedium, storage, medium)
+		if err != nil && !missing(err) {
+			c.logger.Warn("Ignoring medium priority storage error", "storage", storageName(storage), "error", err)
+		}
 		if terminate {
 			return nil
 		}
@@ -92,24 +164,70 @@ func (c *Cache) Loop(high func(s Storage) (bool, error), medium func(s Storage)
 // all string argument passed after expiration will be used to tag the value
 // It ignores any error occurred for medium level storage
 func (c *Cache) Set(key string, v interface{}, expiration time.Duration, tags ...string) error {
-	return c.set(key, v, expiration, tags...)
+	return c.set(context.Background(), key, v, expiration, tags...)
 }
-func (c *Cache) set(key string, v interface{}, expiration time.Duration, tags ...string) (err error) {
-	item := item{Key: key, Val: v, Created: time.Now(), Expires: expiration}
-	return c.Loop(
-		func(s Storage) (bool, error) {
-			return false, c.write(s, key, item, expiration, tags...)
+
+// SetContext is like Set but carries ctx down to the storage
+func (c *Cache) SetContext(ctx context.Context, key string, v interface{}, expiration time.Duration, tags ...string) error {
+	return c.set(ctx, key, v, expiration, tags...)
+}
+func (c *Cache) set(ctx context.Context, key string, v interface{}, expiration time.Duration, tags ...string) (err error) {
+	return c.setItem(ctx, c.newItem(key, v, expiration), tags...)
+}
+
+func (c *Cache) setItem(ctx context.Context, it item, tags ...string) (err error) {
+	ctx, span := c.startSpan(ctx, "Set", []string{it.Key}, tags)
+	defer func() { endSpan(span, err) }()
+
+	return c.loop(
+		ctx,
+		func(ctx context.Context, s Storage) (bool, error) {
+			return false, c.write(ctx, s, it.Key, it, it.ttl(), tags...)
 		},
 		nil,
 	)
 }
 
-func (c *Cache) write(s Storage, key string, v interface{}, expiration time.Duration, tags ...string) error {
-	if err := s.Write(c.NsKey(key), v, expiration); err != nil {
+// newItem returns a new item for the value expiring after expiration,
+// which may be served stale as configured
+func (c *Cache) newItem(key string, v interface{}, expiration time.Duration) item {
+	it := item{Key: key, Val: v, Created: time.Now(), Expires: expiration}
+	if expiration != 0 {
+		it.StaleWhileRevalidate = c.staleWhileRevalidate
+		it.StaleIfError = c.staleIfError
+	}
+	return it
+}
+
+func (c *Cache) write(ctx context.Context, s Storage, key string, it item, expiration time.Duration, tags ...string) error {
+	return c.store(ctx, s, key, it, tags, func(nsKey string, v interface{}) error {
+		return write(ctx, s, nsKey, v, expiration)
+	})
+}
+
+// store encodes the item for s, hands it over to fn to be written and
+// tags it
+func (c *Cache) store(ctx context.Context, s Storage, key string, it item, tags []string, fn func(nsKey string, v interface{}) error) error {
+	versions, err := c.versions(ctx, s, tags)
+	if err != nil {
+		return err
+	}
+	if versions != nil {
+		it.Tags = versions
+	}
+
+	v, err := c.value(s, it)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = fn(c.NsKey(key), v)
+	c.observe(s, OpWrite, time.Since(start), 1, 0, sizeOf(v), err)
+	if err != nil {
 		return err
 	}
 	if len(tags) > 0 {
-		if err := c.tagger.Tag(s, key, tags...); err != nil {
+		if err := c.tagger.Tag(withContext(ctx, s), key, tags...); err != nil {
 			return err
 		}
 	}
@@ -119,82 +237,309 @@ func (c *Cache) write(s Storage, key string, v interface{}, expiration time.Dura
 // Get reads for the given key from the registered storage unless
 // a valid content is received. It will ignore any error occurred
 // for medium level storage
-func (c *Cache) Get(key string, out interface{}) error { return c.get(key, out) }
-func (c *Cache) get(key string, out interface{}) error {
+func (c *Cache) Get(key string, out interface{}) error {
+	_, _, err := c.get(context.Background(), key, decoder(out))
+	return err
+}
+
+// GetContext is like Get but carries ctx down to the storage
+func (c *Cache) GetContext(ctx context.Context, key string, out interface{}) error {
+	_, _, err := c.get(ctx, key, decoder(out))
+	return err
+}
+
+// get reads the value for the given key into decode and returns the item
+// read. Expired items are only read while they may be revalidated, the
+// first other expired item is returned as stale instead so that it can
+// be served if reloading it fails
+func (c *Cache) get(ctx context.Context, key string, decode func(v interface{}) error) (it, stale *item, err error) {
+	ctx, span := c.startSpan(ctx, "Get", []string{key}, nil)
+	defer func() { endSpan(span, err) }()
+
 	var (
-		p  []Storage
-		it *item
-		w  Storage
+		p    []Storage
+		w    Storage
+		tier Priority
 	)
-	err := c.Loop(
-		func(s Storage) (bool, error) {
-			item, err := c.read(s, key)
+	step := func(high bool) func(ctx context.Context, s Storage) (bool, error) {
+		return func(ctx context.Context, s Storage) (bool, error) {
+			item, err := c.read(ctx, s, key)
+			if err == nil && !item.servable() {
+				if stale == nil {
+					stale = item
+				}
+				err = ErrKeyNotExist
+			}
 			if err != nil {
-				if ErrKeyNotExist == err {
+				if high && ErrKeyNotExist == err {
 					p = append(p, s)
 				}
 				return false, err
 			}
 			it = item
 			w = s
-			if err := mapstructure.Decode(item.Val, out); err != nil {
-				return false, err
-			}
-			return true, nil
-		},
-		func(s Storage) (bool, error) {
-			item, err := c.read(s, key)
-			if err != nil {
-				return false, err
+			if tier = PriorityMedium; high {
+				tier = PriorityHigh
 			}
-			it = item
-			w = s
-			if err := mapstructure.Decode(item.Val, out); err != nil {
+			if err := decode(item.Val); err != nil {
 				return false, err
 			}
 			return true, nil
-		},
-	)
+		}
+	}
+	err = c.loop(ctx, step(true), step(false))
 
+	span.SetAttributes(attribute.Bool("cache.hit", it != nil))
 	if it != nil {
+		span.SetAttributes(attribute.String("cache.tier", tier.String()))
+	}
+
+	if it != nil && !it.raw {
 		for _, s := range p {
-			c.propagate(s, w, it, key)
+			c.propagate(ctx, s, w, it, key)
 		}
 	}
-	return err
+	return it, stale, err
+}
+
+// GetOrLoad reads the value for the given key into `out`. If the key
+// does not exist it calls loader, stores the loaded value with the
+// given expiration and tags and decodes it into `out`. Concurrent loads
+// of the same key are deduplicated so that the loader is called once.
+// Loader errors are returned as they are and nothing is stored.
+//
+// Expired values are served while they may be revalidated, see
+// WithStaleWhileRevalidate, and reloaded in the background. They are
+// served as well if the loader fails while they may be, see
+// WithStaleIfError
+func (c *Cache) GetOrLoad(key string, out interface{}, loader func() (interface{}, error), expiration time.Duration, tags ...string) error {
+	return c.getOrLoad(context.Background(), key, decoder(out), loader, expiration, tags...)
+}
+
+// GetOrLoadContext is like GetOrLoad but carries ctx down to the storage
+func (c *Cache) GetOrLoadContext(ctx context.Context, key string, out interface{}, loader func() (interface{}, error), expiration time.Duration, tags ...string) error {
+	return c.getOrLoad(ctx, key, decoder(out), loader, expiration, tags...)
+}
+func (c *Cache) getOrLoad(ctx context.Context, key string, decode func(v interface{}) error, loader func() (interface{}, error), expiration time.Duration, tags ...string) error {
+	it, stale, err := c.get(ctx, key, decode)
+	if it != nil {
+		if it.expired() {
+			c.revalidate(key, loader, expiration, tags...)
+		} else if c.beta > 0 && it.expiresEarly(c.beta) {
+			// the value is still valid if reloading it fails
+			if v, _, err := c.load(ctx, key, loader, expiration, tags...); err == nil {
+				return decode(v)
+			}
+		}
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	v, errSet, err := c.load(ctx, key, loader, expiration, tags...)
+	if err != nil {
+		if stale != nil && stale.staleFor() <= stale.StaleIfError {
+			c.logger.Warn("Serving stale value after failing to load it", "key", key, "error", err)
+			return decode(stale.Val)
+		}
+		return err
+	}
+	if err := decode(v); err != nil {
+		return err
+	}
+	return errSet
+}
+
+// load calls loader once for all the concurrent callers and stores the
+// value along with how long loading it has taken. Only the caller which
+// actually runs the loader gets to know about storage errors
+func (c *Cache) load(ctx context.Context, key string, loader func() (interface{}, error), expiration time.Duration, tags ...string) (v interface{}, errSet, err error) {
+	v, err, _ = c.loads.Do(c.NsKey(key), func() (interface{}, error) {
+		start := time.Now()
+		v, err := loader()
+		if err != nil {
+			return nil, err
+		}
+
+		it := c.newItem(key, v, expiration)
+		it.Delta = time.Since(start)
+		errSet = c.setItem(ctx, it, tags...)
+		return v, nil
+	})
+	return v, errSet, err
+}
+
+// revalidate reloads the value for the given key in the background unless
+// it is being reloaded already
+func (c *Cache) revalidate(key string, loader func() (interface{}, error), expiration time.Duration, tags ...string) {
+	if _, loading := c.revalidating.LoadOrStore(key, struct{}{}); loading {
+		return
+	}
+
+	go func() {
+		defer c.revalidating.Delete(key)
+
+		_, errSet, err := c.load(context.Background(), key, loader, expiration, tags...)
+		if err == nil {
+			err = errSet
+		}
+		if err != nil {
+			c.logger.Warn("Failed to revalidate value", "key", key, "error", err)
+		}
+	}()
 }
-func (c *Cache) read(s Storage, key string) (*item, error) {
-	v, err := s.Read(c.NsKey(key))
+
+func (c *Cache) read(ctx context.Context, s Storage, key string) (it *item, err error) {
+	start := time.Now()
+	v, err := read(ctx, s, c.NsKey(key))
+	took := time.Since(start)
+	defer func() {
+		hits := 0
+		if it != nil {
+			hits = 1
+		}
+		c.observe(s, OpRead, took, 1, hits, sizeOf(v), err)
+		c.annotate(ctx, attribute.Bool("cache.hit", it != nil))
+	}()
 	if err != nil {
 		return nil, err
 	}
 
-	var cacheItem = &item{}
-	switch x := v.(type) {
-	case item:
-		cacheItem = &x
-	case string:
-		json.Unmarshal([]byte(x), cacheItem)
-	case []byte:
-		json.Unmarshal(x, cacheItem)
+	cacheItem, err := c.item(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.alive(ctx, s, key, &cacheItem); err != nil {
+		return nil, err
+	}
+	return &cacheItem, nil
+}
+
+// alive fails with ErrKeyNotExist if the item read from s for key can't
+// be served anymore, deleting it from all the storage if it's dead or
+// because its tags have been invalidated since it's been written
+func (c *Cache) alive(ctx context.Context, s Storage, key string, it *item) error {
+	if c.reap(ctx, s, key, it) {
+		return ErrKeyNotExist
+	}
+	if len(it.Tags) > 0 {
+		versions, err := c.versions(ctx, s, tagsOf(it))
+		if err != nil {
+			return err
+		}
+		if it.outdated(versions) {
+			return ErrKeyNotExist
+		}
+	}
+	return nil
+}
+
+// reap deletes the item read from s for key from all the storage if it's
+// dead, reporting whether it is
+func (c *Cache) reap(ctx context.Context, s Storage, key string, it *item) bool {
+	if !it.dead() {
+		return false
 	}
-	if cacheItem.expired() {
-		return nil, c.del(c.NsKey(key))
+	c.delExcept(ctx, nil, key)
+	c.emit(s, ReasonExpired, it.Val, key)
+	return true
+}
+
+// versions returns the current versions of the given tags in s if the
+// tagger versions tags
+func (c *Cache) versions(ctx context.Context, s Storage, tags []string) (map[string]uint64, error) {
+	versioner, ok := c.tagger.(TagVersioner)
+	if !ok || len(tags) == 0 {
+		return nil, nil
+	}
+	return versioner.Versions(withContext(ctx, s), tags...)
+}
+
+// tagsOf returns the tags the versions of which have been recorded in
+// the given items
+func tagsOf(items ...*item) []string {
+	var (
+		seen = make(map[string]struct{})
+		tags []string
+	)
+	for _, it := range items {
+		for tag := range it.Tags {
+			if _, ok := seen[tag]; !ok {
+				seen[tag] = struct{}{}
+				tags = append(tags, tag)
+			}
+		}
 	}
-	return cacheItem, nil
+	sort.Strings(tags)
+	return tags
 }
 
 // Del deletes the given key from all registered storage
-func (c *Cache) Del(keys ...string) error { return c.del(keys...) }
-func (c *Cache) del(keys ...string) error {
-	return c.Loop(
-		func(s Storage) (bool, error) {
-			for _, key := range keys {
-				if err := s.Delete(c.NsKey(key)); err != nil {
-					return false, err
-				}
+func (c *Cache) Del(keys ...string) error { return c.del(context.Background(), keys...) }
+
+// DelContext is like Del but carries ctx down to the storage
+func (c *Cache) DelContext(ctx context.Context, keys ...string) error { return c.del(ctx, keys...) }
+func (c *Cache) del(ctx context.Context, keys ...string) (err error) {
+	if len(keys) == 0 {
+		return nil
+	}
+	ctx, span := c.startSpan(ctx, "Del", keys, nil)
+	defer func() { endSpan(span, err) }()
+
+	return c.purge(ctx, nil, true, keys...)
+}
 
-				if err := c.tagger.UnTag(s, key); err != nil {
+// shared returns the lowest priority storage for which ok is true, which
+// is usually the one shared by all the processes, e.g. Redis behind an
+// InMemory. Atomic operations run against it, as they wouldn't be atomic
+// across processes in a storage of their own
+func (c *Cache) shared(ok func(s Storage) bool) (Storage, bool) {
+	var shared Storage
+	for _, p := range []Priority{PriorityHigh, PriorityMedium} {
+		for _, s := range c.storage[p] {
+			if ok(s) {
+				shared = s
+			}
+		}
+	}
+	return shared, shared != nil
+}
+
+// delExcept deletes the given keys from all registered storage but except
+func (c *Cache) delExcept(ctx context.Context, except Storage, keys ...string) error {
+	return c.purge(ctx, except, false, keys...)
+}
+
+// purge deletes the given keys from all registered storage but except
+// and reports them to the OnDelete hooks if notify
+func (c *Cache) purge(ctx context.Context, except Storage, notify bool, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.loop(
+		ctx,
+		func(ctx context.Context, s Storage) (bool, error) {
+			if same(s, except) {
+				return false, nil
+			}
+
+			nsKeys := make([]string, len(keys))
+			for i := range keys {
+				nsKeys[i] = c.NsKey(keys[i])
+			}
+			start := time.Now()
+			err := removeMulti(ctx, s, nsKeys...)
+			c.observe(s, OpDelete, time.Since(start), len(keys), 0, 0, err)
+			if err != nil {
+				return false, err
+			}
+			if notify {
+				c.emit(s, ReasonDeleted, nil, keys...)
+			}
+
+			for _, key := range keys {
+				if err := c.tagger.UnTag(withContext(ctx, s), key); err != nil {
 					return false, err
 				}
 			}
@@ -204,22 +549,57 @@ func (c *Cache) del(keys ...string) error {
 	)
 }
 
+// missing reports whether err only reports missing keys
+func missing(err error) bool {
+	if merr, ok := err.(*multierror.Error); ok {
+		for _, err := range merr.Errors {
+			if !missing(err) {
+				return false
+			}
+		}
+		return true
+	}
+	return err == ErrKeyNotExist
+}
+
+// same reports whether a and b are the same storage. Storage of
+// incomparable types are never the same
+func same(a, b Storage) bool {
+	if a == nil || b == nil || reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
+
 // Extend sets the new expiration time for the given key
 // If the expiration has not initially been set this method
 // will add one
 func (c *Cache) Extend(key string, expiration time.Duration) error {
-	return c.Loop(
+	return c.ExtendContext(context.Background(), key, expiration)
+}
+
+// ExtendContext is like Extend but carries ctx down to the storage
+func (c *Cache) ExtendContext(ctx context.Context, key string, expiration time.Duration) error {
+	return c.LoopContext(
+		ctx,
 		func(s Storage) (bool, error) {
-			v, err := s.Read(c.NsKey(key))
+			v, err := read(ctx, s, c.NsKey(key))
 			if err != nil {
 				return false, err
 			}
 
-			it := v.(item)
+			it, err := c.item(v)
+			if err != nil {
+				return false, err
+			}
 			it.Created = time.Now()
 			it.Expires = expiration
 
-			return false, s.Write(key, it, expiration)
+			val, err := c.value(s, it)
+			if err != nil {
+				return false, err
+			}
+			return false, write(ctx, s, c.NsKey(key), val, it.ttl())
 		},
 		nil,
 	)
@@ -227,29 +607,63 @@ func (c *Cache) Extend(key string, expiration time.Duration) error {
 
 // Propagate propagates all the given keys from s1 data storage
 // into s2 data storage
-func (c *Cache) propagate(s1, s2 Storage, it *item, keys ...string) error {
+func (c *Cache) propagate(ctx context.Context, s1, s2 Storage, it *item, keys ...string) (err error) {
+	start := time.Now()
+	defer func() {
+		c.observe(s1, OpPropagate, time.Since(start), len(keys), 0, 0, err)
+		c.logPropagation(s1, s2, keys, err)
+	}()
+
 	for _, key := range keys {
-		tags, err := c.tagger.Tags(s2, key)
+		tags, err := c.tagger.Tags(withContext(ctx, s2), key)
 		if err != nil {
 			return err
 		}
-		New(WithStorage(s1), WithNamespace(c.ns)).Set(key, it.Val, it.Expires-(time.Now().Sub(it.Created)), tags...)
+		if len(it.Tags) > 0 {
+			tags = tagsOf(it)
+		}
+		// the item keeps its own expiration
+		if err := c.write(ctx, s1, key, *it, it.ttl(), tags...); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// logPropagation logs the propagation of keys to s1 from s2, which is
+// nil if the keys come from several storage
+func (c *Cache) logPropagation(s1, s2 Storage, keys []string, err error) {
+	keyvals := []interface{}{"keys", keys, "to", storageName(s1)}
+	if s2 != nil {
+		keyvals = append(keyvals, "from", storageName(s2))
+	}
+	if err != nil {
+		c.logger.Warn("Failed to propagate keys", append(keyvals, "error", err)...)
+		return
+	}
+	c.logger.Debug("Propagated keys", keyvals...)
+}
+
 // Flush flushes all the data in all registered storage
 func (c *Cache) Flush() (err error) {
-	return c.Loop(
+	return c.FlushContext(context.Background())
+}
+
+// FlushContext is like Flush but carries ctx down to the storage
+func (c *Cache) FlushContext(ctx context.Context) (err error) {
+	return c.LoopContext(
+		ctx,
 		func(s Storage) (bool, error) {
-			return false, s.Flush()
+			return false, flush(ctx, s)
 		},
 		nil,
 	)
 }
 
-// Close closes the storage resource if it implements io.Closer
+// Close unregisters the cache from the storage reporting events to it and
+// closes the storage resource if it implements io.Closer
 func (c *Cache) Close() (err error) {
+	c.hooks.close()
 	return c.Loop(
 		func(s Storage) (bool, error) {
 			if closer, ok := s.(io.Closer); ok {
@@ -264,12 +678,22 @@ func (c *Cache) Close() (err error) {
 // ByTag reads tagged values into `out`
 // `out` is always a slice of values
 func (c *Cache) ByTag(tag string, out interface{}) error {
+	return c.ByTagContext(context.Background(), tag, out)
+}
+
+// ByTagContext is like ByTag but carries ctx down to the storage
+func (c *Cache) ByTagContext(ctx context.Context, tag string, out interface{}) error {
 	if out == nil {
 		out = make([]interface{}, 0, 0)
 	}
+	return c.byTag(ctx, tag, decoder(out))
+}
+func (c *Cache) byTag(ctx context.Context, tag string, decode func(v interface{}) error) (err error) {
+	ctx, span := c.startSpan(ctx, "ByTag", nil, []string{tag})
+	defer func() { endSpan(span, err) }()
 
-	return c.Loop(func(s Storage) (bool, error) {
-		keys, err := c.tagger.Keys(s, tag)
+	return c.loop(ctx, func(ctx context.Context, s Storage) (bool, error) {
+		keys, err := c.tagger.Keys(withContext(ctx, s), tag)
 
 		if err != nil {
 			return false, err
@@ -278,25 +702,107 @@ func (c *Cache) ByTag(tag string, out interface{}) error {
 		output := make([]interface{}, 0, len(keys))
 
 		for _, key := range keys {
-			v, errRead := s.Read(c.NsKey(key))
+			v, errRead := read(ctx, s, c.NsKey(key))
+			if errRead == ErrKeyNotExist {
+				c.repair(ctx, s, tag, key)
+				continue
+			}
 			if errRead != nil {
 				err = multierror.Append(err, errRead)
+				continue
+			}
+			it, errItem := c.item(v)
+			if errItem != nil {
+				err = multierror.Append(err, errItem)
+				continue
+			}
+			if errAlive := c.alive(ctx, s, key, &it); errAlive != nil {
+				if errAlive != ErrKeyNotExist {
+					err = multierror.Append(err, errAlive)
+				}
+				continue
 			}
-			it := c.item(v)
-			if it.Val != nil {
+			// expired items are only kept to be served stale by GetOrLoad
+			if !it.expired() && it.Val != nil {
 				output = append(output, it.Val)
 			}
 		}
-		return true, mapstructure.Decode(output, out)
+		return true, decode(output)
+	}, nil)
+}
+
+// TagSize returns the number of keys tagged with tag
+func (c *Cache) TagSize(tag string) (int, error) {
+	return c.TagSizeContext(context.Background(), tag)
+}
+
+// TagSizeContext is like TagSize but carries ctx down to the storage
+func (c *Cache) TagSizeContext(ctx context.Context, tag string) (n int, err error) {
+	err = c.LoopContext(ctx, func(s Storage) (bool, error) {
+		keys, err := c.tagger.Keys(withContext(ctx, s), tag)
+		n = len(keys)
+		return true, err
 	}, nil)
+	return n, err
+}
+
+// repair untags a key found in the keys of tag but missing in s, e.g.
+// because it has expired or been evicted without being untagged
+func (c *Cache) repair(ctx context.Context, s Storage, tag, key string) {
+	tags, err := c.tagger.Tags(withContext(ctx, s), key)
+	if err == nil {
+		if !contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+		err = c.tagger.UnTag(withContext(ctx, s), key, tags...)
+	}
+	if err != nil {
+		c.logger.Warn("Failed to remove missing key from tag index", "key", key, "tag", tag, "storage", storageName(s), "error", err)
+		return
+	}
+	c.logger.Info("Removed missing key from tag index", "key", key, "tags", tags, "storage", storageName(s))
+}
+
+func contains(slice []string, s string) bool {
+	for i := range slice {
+		if slice[i] == s {
+			return true
+		}
+	}
+	return false
 }
 
 // DelByTag deletes tagged values
 func (c *Cache) DelByTag(tags ...string) error {
-	return c.Loop(
-		func(s Storage) (bool, error) {
+	return c.DelByTagContext(context.Background(), tags...)
+}
+
+// DelByTagContext is like DelByTag but carries ctx down to the storage
+func (c *Cache) DelByTagContext(ctx context.Context, tags ...string) (err error) {
+	ctx, span := c.startSpan(ctx, "DelByTag", nil, tags)
+	defer func() { endSpan(span, err) }()
+
+	return c.loop(
+		ctx,
+		func(ctx context.Context, s Storage) (bool, error) {
+			if versioner, ok := c.tagger.(TagVersioner); ok {
+				return false, versioner.Invalidate(withContext(ctx, s), tags...)
+			}
+
 			for _, tag := range tags {
-				keys, err := c.tagger.Keys(s, tag)
+				if deleter, ok := c.tagger.(TagDeleter); ok {
+					keys, err := deleter.DeleteTagged(withContext(ctx, s), c.NsKey(""), tag)
+					if err != nil {
+						return false, err
+					}
+					c.emit(s, ReasonDeleted, nil, keys...)
+					if err := c.purge(ctx, s, true, keys...); err != nil {
+						return false, err
+					}
+					continue
+				}
+
+				keys, err := c.tagger.Keys(withContext(ctx, s), tag)
 				if err != nil {
 					return false, err
 				}
@@ -304,7 +810,7 @@ func (c *Cache) DelByTag(tags ...string) error {
 				var slice = make([]string, len(keys))
 				copy(slice, keys)
 
-				if err := c.Del(slice...); err != nil {
+				if err := c.del(ctx, slice...); err != nil {
 					return false, err
 				}
 			}
@@ -314,17 +820,43 @@ func (c *Cache) DelByTag(tags ...string) error {
 	)
 }
 
-func (c *Cache) item(v interface{}) item {
+// item converts a value read from a storage into an item. Envelopes
+// are decoded with the codec recorded in them, integers are counters
+// and any other value is treated as a JSON encoded item
+func (c *Cache) item(v interface{}) (item, error) {
+	if n, ok := counter(v); ok {
+		return item{Val: n, raw: true}, nil
+	}
+
 	var i item
 	switch x := v.(type) {
 	case item:
-		return x
+		return x, nil
 	case []byte:
+		if isEnvelope(x) {
+			return decodeItem(c.enc, x)
+		}
 		json.Unmarshal(x, &i)
 	case string:
+		if isEnvelope([]byte(x)) {
+			return decodeItem(c.enc, []byte(x))
+		}
 		json.Unmarshal([]byte(x), &i)
 	default:
-		mapstructure.Decode(v, &i)
+		// storage decoding into generic maps, e.g. Fs, loses the
+		// original field types so round trip through JSON
+		b, _ := json.Marshal(v)
+		json.Unmarshal(b, &i)
+	}
+	return i, nil
+}
+
+// value returns what is to be written to the storage for the item.
+// Byte storage get the item encoded with the cache codec, any other
+// storage gets the item as it is
+func (c *Cache) value(s Storage, it item) (interface{}, error) {
+	if _, ok := s.(ByteStorage); !ok {
+		return it, nil
 	}
-	return i
+	return encodeItem(c.enc, it)
 }
@@ -1,6 +1,9 @@
 package cache
 
 import (
+	"context"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -42,6 +45,51 @@ func (m *mock) Close() error {
 	return m.err
 }
 
+type ctxKey struct{}
+
+// ctxMock records the context it has been called with
+type ctxMock struct {
+	*InMem
+	ctx context.Context
+}
+
+func (m *ctxMock) WriteContext(ctx context.Context, key string, v interface{}, ttl time.Duration) error {
+	m.ctx = ctx
+	return m.Write(key, v, ttl)
+}
+
+func (m *ctxMock) ReadContext(ctx context.Context, key string) (interface{}, error) {
+	m.ctx = ctx
+	return m.Read(key)
+}
+
+func (m *ctxMock) DeleteContext(ctx context.Context, key string) error {
+	m.ctx = ctx
+	return m.Delete(key)
+}
+
+func (m *ctxMock) FlushContext(ctx context.Context) error {
+	m.ctx = ctx
+	return m.Flush()
+}
+
+// batchMock counts the batch calls it receives
+type batchMock struct {
+	*InMem
+	reads  int
+	writes int
+}
+
+func (m *batchMock) ReadMulti(ctx context.Context, keys ...string) (map[string]interface{}, error) {
+	m.reads++
+	return m.InMem.ReadMulti(ctx, keys...)
+}
+
+func (m *batchMock) WriteMulti(ctx context.Context, values map[string]interface{}, ttl time.Duration) error {
+	m.writes++
+	return m.InMem.WriteMulti(ctx, values, ttl)
+}
+
 type some struct {
 	Field1 int
 	Field2 string
@@ -420,11 +468,27 @@ func TestCache_Extend(t *testing.T) {
 
 	assert.NilError(t, c.Extend("key2", 2*time.Second))
 	for _, s := range inMem {
-		it, _ := s.Read("key2")
+		it, _ := s.Read(c.NsKey("key2"))
 		assert.DeepEqual(t, it.(item).Expires, 2*time.Second)
 	}
 }
 
+func TestCache_ExtendNamespace(t *testing.T) {
+	c := New(
+		WithStorage(InMemory()),
+		WithNamespace("go:test"),
+	)
+
+	c.Set("key1", 1234, 50*time.Millisecond)
+	assert.NilError(t, c.Extend("key1", time.Minute))
+
+	time.Sleep(100 * time.Millisecond)
+
+	var v int
+	assert.NilError(t, c.Get("key1", &v))
+	assert.Equal(t, v, 1234)
+}
+
 func TestCache_Close(t *testing.T) {
 	var (
 		inMem = []Storage{&mock{}, InMemory(), InMemory(), InMemory(), &mock{}}
@@ -453,6 +517,33 @@ func TestCache_ByTagIntegers(t *testing.T) {
 	assert.DeepEqual(t, intarr, []int{1, 2, 3, 4})
 }
 
+func TestCache_ByTagExpired(t *testing.T) {
+	s := InMemory()
+	c := New(WithStorage(s), WithStaleIfError(time.Minute))
+	expired := &events{}
+	c.OnExpire(expired.record)
+
+	c.Set("key1", 1, 10*time.Millisecond, "tag1")
+	c.Set("key2", 2, 0, "tag1")
+	time.Sleep(20 * time.Millisecond)
+
+	// key1 is kept to be served stale by GetOrLoad only
+	var ints []int
+	assert.NilError(t, c.ByTag("tag1", &ints))
+	assert.DeepEqual(t, []int{2}, ints)
+	assert.Equal(t, 0, len(expired.events))
+
+	assert.NilError(t, s.Write("go:cache:key1", item{Key: "key1", Val: 1, Created: time.Now().Add(-time.Hour), Expires: time.Minute}, 0))
+	assert.NilError(t, c.ByTag("tag1", &ints))
+	assert.DeepEqual(t, []int{2}, ints)
+	assert.DeepEqual(t, []Event{
+		{Key: "key1", Storage: "inmem", Priority: PriorityHigh, Reason: ReasonExpired, Value: 1},
+	}, expired.events)
+
+	_, err := s.Read("go:cache:key1")
+	assert.Equal(t, ErrKeyNotExist, err)
+}
+
 func TestCache_ByTagFloats(t *testing.T) {
 	c := New(WithStorage(InMemory()))
 	c.Set("key1", 1.1, 0, "tag1", "tag2", "tag3")
@@ -551,6 +642,25 @@ func Test_DelByTag(t *testing.T) {
 	}
 }
 
+func TestCache_TagSize(t *testing.T) {
+	c := New(WithStorage(InMemory()))
+	c.Set("key1", 123, 0, "tag1", "tag2")
+	c.Set("key2", 456, 0, "tag1")
+
+	n, err := c.TagSize("tag1")
+	assert.NilError(t, err)
+	assert.Equal(t, 2, n)
+
+	assert.NilError(t, c.Del("key1"))
+	n, err = c.TagSize("tag2")
+	assert.NilError(t, err)
+	assert.Equal(t, 0, n)
+
+	c = New(WithStorage(InMemory()), WithTagger(VersionTagger("go:cache:tagger")))
+	_, err = c.TagSize("tag1")
+	assert.ErrorContains(t, err, ErrNotSupported.Error())
+}
+
 func TestCache_Propagate(t *testing.T) {
 	var (
 		s1 = InMemory()
@@ -567,3 +677,347 @@ func TestCache_Propagate(t *testing.T) {
 	assert.Assert(t, ok)
 	assert.Equal(t, it.(item).Val, 12345)
 }
+
+func TestCache_LoopContextCanceled(t *testing.T) {
+	c := New(
+		WithHighPriorityStorage(InMemory(), InMemory()),
+		WithMediumPriorityStorage(InMemory()),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var called int
+	err := c.LoopContext(ctx, func(s Storage) (bool, error) {
+		called++
+		return false, nil
+	}, nil)
+	assert.Equal(t, 0, called)
+	assert.Assert(t, errors.Is(err, context.Canceled))
+}
+
+func TestCache_LoopContextStopsEarly(t *testing.T) {
+	c := New(
+		WithHighPriorityStorage(InMemory(), InMemory(), InMemory()),
+		WithMediumPriorityStorage(InMemory(), InMemory()),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var called int
+	err := c.LoopContext(ctx, func(s Storage) (bool, error) {
+		called++
+		if called == 2 {
+			cancel()
+		}
+		return false, nil
+	}, nil)
+	assert.Equal(t, 2, called)
+	assert.Assert(t, errors.Is(err, context.Canceled))
+}
+
+func TestCache_ContextReachesStorage(t *testing.T) {
+	s := &ctxMock{InMem: InMemory()}
+	c := New(WithStorage(s))
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	assert.NilError(t, c.SetContext(ctx, "key1", 1234, 0))
+	assert.Equal(t, s.ctx.Value(ctxKey{}), "value")
+
+	s.ctx = nil
+	var i int
+	assert.NilError(t, c.GetContext(ctx, "key1", &i))
+	assert.Equal(t, 1234, i)
+	assert.Equal(t, s.ctx.Value(ctxKey{}), "value")
+
+	s.ctx = nil
+	assert.NilError(t, c.DelContext(ctx, "key1"))
+	assert.Equal(t, s.ctx.Value(ctxKey{}), "value")
+}
+
+func TestCache_GetContextCanceled(t *testing.T) {
+	c := New(WithStorage(InMemory()))
+	c.Set("key1", 1234, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var i int
+	err := c.GetContext(ctx, "key1", &i)
+	assert.Assert(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, 0, i)
+}
+
+func TestCache_GetOrLoad(t *testing.T) {
+	inMem := InMemory()
+	c := New(WithStorage(inMem), WithNamespace("go:test"))
+
+	var calls int
+	loader := func() (interface{}, error) {
+		calls++
+		return 1234, nil
+	}
+
+	var i int
+	assert.NilError(t, c.GetOrLoad("key1", &i, loader, time.Minute, "tag1"))
+	assert.Equal(t, 1234, i)
+	assert.Equal(t, 1, calls)
+
+	it, ok := inMem.data["go:test:key1"]
+	assert.Assert(t, ok)
+	assert.Equal(t, it.(item).Expires, time.Minute)
+	assert.DeepEqual(t, inMem.data["go:cache:tagger:tag:tag1:keys"], []string{"key1"})
+
+	var j int
+	assert.NilError(t, c.GetOrLoad("key1", &j, loader, time.Minute))
+	assert.Equal(t, 1234, j)
+	assert.Equal(t, 1, calls)
+}
+
+func TestCache_GetOrLoadDeduplicates(t *testing.T) {
+	c := New(WithStorage(InMemory()))
+
+	var (
+		calls int32
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+	)
+	loader := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(100 * time.Millisecond)
+		return "val", nil
+	}
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			var v string
+			assert.Check(t, c.GetOrLoad("key1", &v, loader, 0))
+			assert.Check(t, v == "val")
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestCache_GetOrLoadError(t *testing.T) {
+	inMem := InMemory()
+	c := New(WithStorage(inMem), WithNamespace("go:test"))
+
+	var i int
+	err := c.GetOrLoad("key1", &i, func() (interface{}, error) {
+		return nil, errors.New("loader error")
+	}, 0)
+	assert.Error(t, err, "loader error")
+
+	_, ok := inMem.data["go:test:key1"]
+	assert.Assert(t, !ok)
+
+	assert.NilError(t, c.GetOrLoad("key1", &i, func() (interface{}, error) {
+		return 1234, nil
+	}, 0))
+	assert.Equal(t, 1234, i)
+}
+
+func TestCache_GetMulti(t *testing.T) {
+	var (
+		s1 = InMemory()
+		s2 = &batchMock{InMem: InMemory()}
+		s3 = InMemory()
+	)
+	New(WithStorage(s1, s2, s3), WithNamespace("go:test")).Set("key1", 1, 0)
+	New(WithStorage(s3), WithNamespace("go:test")).Set("key2", 2, time.Minute, "tag1")
+	New(WithStorage(s3), WithNamespace("go:test")).Set("key3", 3, 0)
+
+	c := New(
+		WithHighPriorityStorage(s1, s2),
+		WithMediumPriorityStorage(s3),
+		WithNamespace("go:test"),
+	)
+	s2.reads, s2.writes = 0, 0
+
+	var out map[string]int
+	assert.NilError(t, c.GetMulti([]string{"key1", "key2", "key3", "key4"}, &out))
+	assert.DeepEqual(t, out, map[string]int{"key1": 1, "key2": 2, "key3": 3})
+
+	assert.Equal(t, 1, s2.reads)
+	assert.Equal(t, 1, s2.writes)
+
+	for _, s := range []*InMem{s1, s2.InMem} {
+		it, ok := s.data["go:test:key2"]
+		assert.Assert(t, ok)
+		assert.Equal(t, it.(item).Val, 2)
+		assert.Equal(t, it.(item).Expires, time.Minute)
+		assert.DeepEqual(t, s.data["go:cache:tagger:tag:tag1:keys"], []string{"key2"})
+
+		_, ok = s.data["go:test:key3"]
+		assert.Assert(t, ok)
+
+		_, ok = s.data["go:test:key4"]
+		assert.Assert(t, !ok)
+	}
+}
+
+func TestCache_GetMultiFallback(t *testing.T) {
+	c := New(WithStorage(Filesystem(t.TempDir())))
+	c.Set("key1", "abc", 0)
+	c.Set("key2", "def", 0)
+
+	var out map[string]string
+	assert.NilError(t, c.GetMulti([]string{"key1", "key2", "key3"}, &out))
+	assert.DeepEqual(t, out, map[string]string{"key1": "abc", "key2": "def"})
+}
+
+func TestCache_GetMultiStaleWhileRevalidate(t *testing.T) {
+	s := InMemory()
+	c := New(WithStorage(s), WithStaleWhileRevalidate(time.Minute))
+	assert.NilError(t, c.Set("key1", 1, 10*time.Millisecond))
+	assert.NilError(t, New(WithStorage(s), WithStaleIfError(time.Minute)).Set("key2", 2, 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+
+	// stale values are served as by Get, unless only if loading fails
+	var out map[string]int
+	assert.NilError(t, c.GetMulti([]string{"key1", "key2"}, &out))
+	assert.DeepEqual(t, out, map[string]int{"key1": 1})
+
+	var v int
+	assert.NilError(t, c.GetOrLoad("key2", &v, func() (interface{}, error) {
+		return nil, errors.New("origin is down")
+	}, time.Minute))
+	assert.Equal(t, 2, v)
+}
+
+func TestCache_SetMulti(t *testing.T) {
+	var (
+		s1 = &batchMock{InMem: InMemory()}
+		s2 = InMemory()
+	)
+	c := New(WithStorage(s1, s2), WithNamespace("go:test"))
+
+	assert.NilError(t, c.SetMulti(map[string]interface{}{
+		"key1": 1,
+		"key2": "abc",
+	}, time.Minute, "tag1"))
+	assert.Equal(t, 1, s1.writes)
+
+	for _, s := range []*InMem{s1.InMem, s2} {
+		assert.Equal(t, s.data["go:test:key1"].(item).Val, 1)
+		assert.Equal(t, s.data["go:test:key2"].(item).Val, "abc")
+		assert.DeepEqual(t, s.data["go:cache:tagger:tag:tag1:keys"], []string{"key1", "key2"})
+	}
+
+	assert.NilError(t, c.Del("key1", "key2"))
+	for _, s := range []*InMem{s1.InMem, s2} {
+		_, ok := s.data["go:test:key1"]
+		assert.Assert(t, !ok)
+		_, ok = s.data["go:cache:tagger:tag:tag1:keys"]
+		assert.Assert(t, !ok)
+	}
+}
+
+func TestCache_PropagateBytes(t *testing.T) {
+	var (
+		high = Memcached(memcached(t))
+		low  = Memcached(memcached(t))
+	)
+	assert.NilError(t, New(WithStorage(low)).Set("key1", map[string]int{"a": 1}, time.Minute))
+
+	var v map[string]int
+	c := New(WithHighPriorityStorage(high), WithMediumPriorityStorage(low))
+	assert.NilError(t, c.Get("key1", &v))
+
+	v = nil
+	assert.NilError(t, New(WithStorage(high)).Get("key1", &v))
+	assert.DeepEqual(t, map[string]int{"a": 1}, v)
+}
+
+func TestCache_GetOrLoadStaleWhileRevalidate(t *testing.T) {
+	c := New(WithStorage(InMemory()), WithStaleWhileRevalidate(time.Minute))
+
+	var loads int32
+	loader := func() (interface{}, error) {
+		return int(atomic.AddInt32(&loads, 1)), nil
+	}
+
+	var v int
+	assert.NilError(t, c.GetOrLoad("key1", &v, loader, 10*time.Millisecond))
+	assert.Equal(t, 1, v)
+	time.Sleep(20 * time.Millisecond)
+
+	// the stale value is served while reloaded in the background
+	assert.NilError(t, c.GetOrLoad("key1", &v, loader, 10*time.Millisecond))
+	assert.Equal(t, 1, v)
+	assert.NilError(t, c.Get("key1", &v))
+	assert.Equal(t, 1, v)
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&loads) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&loads))
+	for v != 2 && time.Now().Before(deadline) {
+		assert.NilError(t, c.Get("key1", &v))
+	}
+	assert.Equal(t, 2, v)
+}
+
+func TestCache_GetOrLoadStaleIfError(t *testing.T) {
+	var (
+		s      = InMemory()
+		c      = New(WithStorage(s), WithStaleIfError(time.Minute))
+		failed = func() (interface{}, error) { return nil, errors.New("origin is down") }
+		v      int
+	)
+	assert.NilError(t, c.Set("key1", 1, 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+
+	// expired values are only served if the loader fails
+	assert.ErrorContains(t, c.Get("key1", &v), ErrKeyNotExist.Error())
+	assert.NilError(t, c.GetOrLoad("key1", &v, failed, time.Minute))
+	assert.Equal(t, 1, v)
+
+	assert.NilError(t, c.GetOrLoad("key1", &v, func() (interface{}, error) { return 2, nil }, time.Minute))
+	assert.Equal(t, 2, v)
+
+	c = New(WithStorage(s))
+	assert.NilError(t, c.Set("key1", 1, 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	assert.Error(t, c.GetOrLoad("key1", &v, failed, time.Minute), "origin is down")
+}
+
+func TestCache_GetDeletesExpired(t *testing.T) {
+	s := InMemory()
+	c := New(WithStorage(s))
+	assert.NilError(t, s.Write("go:cache:key1", item{Key: "key1", Val: 1, Created: time.Now().Add(-time.Hour), Expires: time.Minute}, 0))
+
+	var v int
+	assert.ErrorContains(t, c.Get("key1", &v), ErrKeyNotExist.Error())
+	_, ok := s.data["go:cache:key1"]
+	assert.Assert(t, !ok)
+}
+
+func TestCache_GetOrLoadEarlyExpiration(t *testing.T) {
+	var loads int
+	loader := func() (interface{}, error) {
+		loads++
+		time.Sleep(time.Millisecond)
+		return loads, nil
+	}
+
+	// a huge beta expires values as soon as they have been loaded
+	c := New(WithStorage(InMemory()), WithEarlyExpiration(1e9))
+	var v int
+	assert.NilError(t, c.GetOrLoad("key1", &v, loader, time.Hour))
+	assert.NilError(t, c.GetOrLoad("key1", &v, loader, time.Hour))
+	assert.Equal(t, 2, v)
+
+	c = New(WithStorage(InMemory()))
+	loads = 0
+	assert.NilError(t, c.GetOrLoad("key1", &v, loader, time.Hour))
+	assert.NilError(t, c.GetOrLoad("key1", &v, loader, time.Hour))
+	assert.Equal(t, 1, v)
+}
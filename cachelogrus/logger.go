@@ -0,0 +1,50 @@
+// Package cachelogrus adapts a logrus logger to a cache.Logger
+package cachelogrus
+
+import (
+	"fmt"
+
+	cache "github.com/apzuk3/go-cache"
+	"github.com/sirupsen/logrus"
+)
+
+type logger struct {
+	l logrus.FieldLogger
+}
+
+// New adapts l to a cache.Logger, which can be passed to
+// cache.New(cache.WithLogger(...)). The keys and values of the events
+// are logged as fields
+func New(l logrus.FieldLogger) cache.Logger {
+	return logger{l: l}
+}
+
+func (l logger) Debug(msg string, keyvals ...interface{}) {
+	l.l.WithFields(fields(keyvals)).Debug(msg)
+}
+
+func (l logger) Info(msg string, keyvals ...interface{}) {
+	l.l.WithFields(fields(keyvals)).Info(msg)
+}
+
+func (l logger) Warn(msg string, keyvals ...interface{}) {
+	l.l.WithFields(fields(keyvals)).Warn(msg)
+}
+
+func (l logger) Error(msg string, keyvals ...interface{}) {
+	l.l.WithFields(fields(keyvals)).Error(msg)
+}
+
+// fields converts alternating keys and values into fields. A value
+// missing its key is logged as !BADKEY like log/slog does
+func fields(keyvals []interface{}) logrus.Fields {
+	fields := make(logrus.Fields, len(keyvals)/2)
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 == len(keyvals) {
+			fields["!BADKEY"] = keyvals[i]
+			break
+		}
+		fields[fmt.Sprint(keyvals[i])] = keyvals[i+1]
+	}
+	return fields
+}
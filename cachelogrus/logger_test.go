@@ -0,0 +1,38 @@
+package cachelogrus
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	cache "github.com/apzuk3/go-cache"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"gotest.tools/assert"
+)
+
+func TestLogger(t *testing.T) {
+	l, hook := test.NewNullLogger()
+	l.SetLevel(logrus.DebugLevel)
+	c := cache.New(cache.WithStorage(cache.InMemory()), cache.WithStaleIfError(time.Minute), cache.WithLogger(New(l)))
+
+	assert.NilError(t, c.Set("key1", "abc", time.Nanosecond))
+	time.Sleep(time.Millisecond)
+
+	var v string
+	err := c.GetOrLoad("key1", &v, func() (interface{}, error) {
+		return nil, errors.New("origin is down")
+	}, time.Minute)
+	assert.NilError(t, err)
+	assert.Equal(t, "abc", v)
+
+	entry := hook.LastEntry()
+	assert.Equal(t, logrus.WarnLevel, entry.Level)
+	assert.Equal(t, "Serving stale value after failing to load it", entry.Message)
+	assert.Equal(t, "key1", entry.Data["key"])
+	assert.ErrorContains(t, entry.Data["error"].(error), "origin is down")
+}
+
+func Test_fields(t *testing.T) {
+	assert.DeepEqual(t, logrus.Fields{"key": "key1", "!BADKEY": 2}, fields([]interface{}{"key", "key1", 2}))
+}
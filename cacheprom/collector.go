@@ -0,0 +1,181 @@
+// Package cacheprom exports the stats of a cache.Cache to Prometheus
+package cacheprom
+
+import (
+	"sort"
+	"strconv"
+
+	cache "github.com/apzuk3/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a prometheus.Collector exporting the stats of a cache. It
+// can be registered with prometheus.MustRegister(cacheprom.New(c))
+type Collector struct {
+	c     *cache.Cache
+	ns    string
+	names []string
+	tags  []string
+
+	hits         *prometheus.Desc
+	misses       *prometheus.Desc
+	errors       *prometheus.Desc
+	writes       *prometheus.Desc
+	deletes      *prometheus.Desc
+	propagations *prometheus.Desc
+	evictions    *prometheus.Desc
+	bytesRead    *prometheus.Desc
+	bytesWritten *prometheus.Desc
+	latency      *prometheus.Desc
+	tagKeys      *prometheus.Desc
+}
+
+// Option is the type of constructor options for New(...)
+type Option func(c *Collector)
+
+// WithNamespace configures the prefix of the metric names. It's
+// "go_cache" by default
+func WithNamespace(ns string) Option {
+	return func(c *Collector) {
+		c.ns = ns
+	}
+}
+
+// WithStorageNames names the storage of the cache in the storage label,
+// in the order they're looped over: high priority storage first, then
+// medium priority storage, each in the order they've been configured in.
+// Storage are named after their type by default, e.g. inmem or redis
+func WithStorageNames(names ...string) Option {
+	return func(c *Collector) {
+		c.names = names
+	}
+}
+
+// WithTags exports the number of keys tagged with the given tags. Tags
+// the keys of which can't be counted, e.g. with a VersionTagger, are
+// skipped
+func WithTags(tags ...string) Option {
+	return func(c *Collector) {
+		c.tags = tags
+	}
+}
+
+// New creates a new Collector exporting the stats of c
+func New(c *cache.Cache, options ...Option) *Collector {
+	collector := &Collector{c: c, ns: "go_cache"}
+	for _, option := range options {
+		option(collector)
+	}
+
+	var (
+		labels = []string{"storage", "priority"}
+		desc   = func(name, help string, labels ...string) *prometheus.Desc {
+			return prometheus.NewDesc(prometheus.BuildFQName(collector.ns, "", name), help, labels, nil)
+		}
+	)
+	collector.hits = desc("hits_total", "Number of keys found in a storage.", labels...)
+	collector.misses = desc("misses_total", "Number of keys missing in a storage.", labels...)
+	collector.errors = desc("errors_total", "Number of failed calls to a storage.", labels...)
+	collector.writes = desc("writes_total", "Number of keys written to a storage.", labels...)
+	collector.deletes = desc("deletes_total", "Number of keys deleted from a storage.", labels...)
+	collector.propagations = desc("propagations_total", "Number of keys propagated to a storage from a lower one.", labels...)
+	collector.evictions = desc("evictions_total", "Number of entries evicted by a bounded storage.", labels...)
+	collector.bytesRead = desc("read_bytes_total", "Number of bytes read from a byte storage.", labels...)
+	collector.bytesWritten = desc("written_bytes_total", "Number of bytes written to a byte storage.", labels...)
+	collector.latency = desc("operation_duration_seconds", "Latency of the calls to a storage.", append(labels, "op")...)
+	collector.tagKeys = desc("tag_keys", "Number of keys tagged with a tag.", "tag")
+	return collector
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.errors
+	ch <- c.writes
+	ch <- c.deletes
+	ch <- c.propagations
+	ch <- c.evictions
+	ch <- c.bytesRead
+	ch <- c.bytesWritten
+	ch <- c.latency
+	ch <- c.tagKeys
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.c.Stats()
+	names := c.storageNames(stats)
+
+	for i, s := range stats.Storage {
+		labels := []string{names[i], s.Priority.String()}
+		counter := func(desc *prometheus.Desc, v uint64) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(v), labels...)
+		}
+		counter(c.hits, s.Hits)
+		counter(c.misses, s.Misses)
+		counter(c.errors, s.Errors)
+		counter(c.writes, s.Writes)
+		counter(c.deletes, s.Deletes)
+		counter(c.propagations, s.Propagations)
+		counter(c.evictions, s.Evictions)
+		counter(c.bytesRead, s.BytesRead)
+		counter(c.bytesWritten, s.BytesWritten)
+
+		ops := make([]string, 0, len(s.Latency))
+		for op := range s.Latency {
+			ops = append(ops, string(op))
+		}
+		sort.Strings(ops)
+		for _, op := range ops {
+			h := s.Latency[cache.Op(op)]
+			ch <- prometheus.MustNewConstHistogram(c.latency, h.Count, h.Sum.Seconds(), buckets(h), append(labels, op)...)
+		}
+	}
+
+	for _, tag := range c.tags {
+		n, err := c.c.TagSize(tag)
+		if err != nil {
+			// an invalid metric would fail the whole scrape
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.tagKeys, prometheus.GaugeValue, float64(n), tag)
+	}
+}
+
+// storageNames returns the configured names of the storage, defaulting
+// to their type names suffixed with their index if several storage share
+// a type
+func (c *Collector) storageNames(stats cache.Stats) []string {
+	var (
+		names = make([]string, len(stats.Storage))
+		count = make(map[string]int)
+	)
+	for _, s := range stats.Storage {
+		count[s.Name]++
+	}
+	for i, s := range stats.Storage {
+		switch {
+		case i < len(c.names):
+			names[i] = c.names[i]
+		case count[s.Name] > 1:
+			names[i] = s.Name + strconv.Itoa(i)
+		default:
+			names[i] = s.Name
+		}
+	}
+	return names
+}
+
+// buckets converts the buckets of h into cumulative Prometheus buckets
+func buckets(h cache.Histogram) map[float64]uint64 {
+	var (
+		buckets = make(map[float64]uint64, len(h.Bounds))
+		count   uint64
+	)
+	for i, bound := range h.Bounds {
+		count += h.Counts[i]
+		buckets[bound.Seconds()] = count
+	}
+	return buckets
+}
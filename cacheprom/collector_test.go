@@ -0,0 +1,93 @@
+package cacheprom
+
+import (
+	"strings"
+	"testing"
+
+	cache "github.com/apzuk3/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/assert"
+)
+
+func TestCollector(t *testing.T) {
+	c := cache.New(
+		cache.WithHighPriorityStorage(cache.InMemory(cache.WithMaxEntries(2))),
+		cache.WithMediumPriorityStorage(cache.InMemory()),
+	)
+
+	// key1 is evicted from the bounded storage, then key2 once key1 is
+	// propagated back
+	assert.NilError(t, c.Set("key1", "abc", 0))
+	assert.NilError(t, c.Set("key2", "abc", 0))
+	assert.NilError(t, c.Set("key3", "abc", 0))
+
+	var v string
+	assert.NilError(t, c.Get("key3", &v))
+	assert.NilError(t, c.Get("key1", &v))
+	assert.ErrorContains(t, c.Get("key4", &v), cache.ErrKeyNotExist.Error())
+
+	collector := New(c, WithStorageNames("l1"))
+
+	expected := `
+# HELP go_cache_hits_total Number of keys found in a storage.
+# TYPE go_cache_hits_total counter
+go_cache_hits_total{priority="high",storage="l1"} 1
+go_cache_hits_total{priority="medium",storage="inmem1"} 1
+# HELP go_cache_misses_total Number of keys missing in a storage.
+# TYPE go_cache_misses_total counter
+go_cache_misses_total{priority="high",storage="l1"} 2
+go_cache_misses_total{priority="medium",storage="inmem1"} 1
+# HELP go_cache_propagations_total Number of keys propagated to a storage from a lower one.
+# TYPE go_cache_propagations_total counter
+go_cache_propagations_total{priority="high",storage="l1"} 1
+go_cache_propagations_total{priority="medium",storage="inmem1"} 0
+# HELP go_cache_evictions_total Number of entries evicted by a bounded storage.
+# TYPE go_cache_evictions_total counter
+go_cache_evictions_total{priority="high",storage="l1"} 2
+go_cache_evictions_total{priority="medium",storage="inmem1"} 0
+`
+	assert.NilError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"go_cache_hits_total", "go_cache_misses_total", "go_cache_propagations_total", "go_cache_evictions_total"))
+
+	// a histogram per storage and operation
+	assert.Equal(t, 2*4, testutil.CollectAndCount(collector, "go_cache_operation_duration_seconds"))
+
+	problems, err := testutil.CollectAndLint(collector)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(problems))
+}
+
+func TestCollector_Tags(t *testing.T) {
+	c := cache.New(cache.WithStorage(cache.InMemory()))
+	assert.NilError(t, c.Set("key1", "abc", 0, "tag1"))
+	assert.NilError(t, c.Set("key2", "abc", 0, "tag1", "tag2"))
+
+	expected := `
+# HELP myapp_tag_keys Number of keys tagged with a tag.
+# TYPE myapp_tag_keys gauge
+myapp_tag_keys{tag="tag1"} 2
+myapp_tag_keys{tag="tag2"} 1
+myapp_tag_keys{tag="tag3"} 0
+`
+	collector := New(c, WithNamespace("myapp"), WithTags("tag1", "tag2", "tag3"))
+	assert.NilError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "myapp_tag_keys"))
+}
+
+func TestCollector_TagsNotSupported(t *testing.T) {
+	c := cache.New(
+		cache.WithStorage(cache.InMemory()),
+		cache.WithTagger(cache.VersionTagger("go:cache:tagger")),
+	)
+	assert.NilError(t, c.Set("key1", "abc", 0, "tag1"))
+
+	collector := New(c, WithTags("tag1"))
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+
+	// the other metrics are still exported
+	n, err := testutil.GatherAndCount(registry)
+	assert.NilError(t, err)
+	assert.Assert(t, n > 0)
+	assert.Equal(t, 0, testutil.CollectAndCount(collector, "go_cache_tag_keys"))
+}
@@ -0,0 +1,34 @@
+// Package cachezap adapts a zap logger to a cache.Logger
+package cachezap
+
+import (
+	cache "github.com/apzuk3/go-cache"
+	"go.uber.org/zap"
+)
+
+type logger struct {
+	l *zap.SugaredLogger
+}
+
+// New adapts l to a cache.Logger, which can be passed to
+// cache.New(cache.WithLogger(...)). The keys and values of the events
+// are logged as fields
+func New(l *zap.Logger) cache.Logger {
+	return logger{l: l.WithOptions(zap.AddCallerSkip(1)).Sugar()}
+}
+
+func (l logger) Debug(msg string, keyvals ...interface{}) {
+	l.l.Debugw(msg, keyvals...)
+}
+
+func (l logger) Info(msg string, keyvals ...interface{}) {
+	l.l.Infow(msg, keyvals...)
+}
+
+func (l logger) Warn(msg string, keyvals ...interface{}) {
+	l.l.Warnw(msg, keyvals...)
+}
+
+func (l logger) Error(msg string, keyvals ...interface{}) {
+	l.l.Errorw(msg, keyvals...)
+}
@@ -0,0 +1,34 @@
+package cachezap
+
+import (
+	"testing"
+
+	cache "github.com/apzuk3/go-cache"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"gotest.tools/assert"
+)
+
+func TestLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	high, medium := cache.InMemory(), cache.InMemory()
+	c := cache.New(
+		cache.WithHighPriorityStorage(high),
+		cache.WithMediumPriorityStorage(medium),
+		cache.WithLogger(New(zap.New(core))),
+	)
+
+	assert.NilError(t, medium.Write("go:cache:key1", []byte(`{"key":"key1","val":"abc"}`), 0))
+	var v string
+	assert.NilError(t, c.Get("key1", &v))
+
+	entries := logs.FilterMessage("Propagated keys").All()
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, zapcore.DebugLevel, entries[0].Level)
+	assert.DeepEqual(t, map[string]interface{}{
+		"keys": []interface{}{"key1"},
+		"to":   "inmem",
+		"from": "inmem",
+	}, entries[0].ContextMap())
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrVersionMismatch indicates that the value has been written by someone
+// else since its version has been read
+var ErrVersionMismatch = errors.New("version mismatch")
+
+// GetWithVersion reads the value for the given key into `out` and returns
+// its version, which can be passed to CompareAndSet to write the key back
+// unless it has been written in between. The value is read from the lowest
+// priority storage implementing CASStorage only, ErrNotSupported is
+// returned if there is none
+func (c *Cache) GetWithVersion(key string, out interface{}) (uint64, error) {
+	return c.GetWithVersionContext(context.Background(), key, out)
+}
+
+// GetWithVersionContext is like GetWithVersion but carries ctx down to
+// the storage
+func (c *Cache) GetWithVersionContext(ctx context.Context, key string, out interface{}) (uint64, error) {
+	cs, ok := c.casStorage()
+	if !ok {
+		return 0, ErrNotSupported
+	}
+
+	start := time.Now()
+	v, version, err := cs.ReadVersion(ctx, c.NsKey(key))
+	hits := 0
+	if err == nil {
+		hits = 1
+	}
+	c.observe(cs.(Storage), OpRead, time.Since(start), 1, hits, sizeOf(v), err)
+	if err != nil {
+		return 0, err
+	}
+	it, err := c.item(v)
+	if err != nil {
+		return 0, err
+	}
+	if it.expired() {
+		return 0, ErrKeyNotExist
+	}
+	if len(it.Tags) > 0 {
+		versions, err := c.versions(ctx, cs.(Storage), tagsOf(&it))
+		if err != nil {
+			return 0, err
+		}
+		if it.outdated(versions) {
+			return 0, ErrKeyNotExist
+		}
+	}
+	return version, decoder(out)(it.Val)
+}
+
+// CompareAndSet stores the value for the given key if it still has the
+// version returned by GetWithVersion and fails with ErrVersionMismatch
+// otherwise. The value is written to the storage GetWithVersion reads from
+// and on success the key is deleted from the other storage so that none
+// of them keeps the previous value. The value keeps the tags of the
+// previous one, which are read again with a VersionTagger as the values
+// record the versions of their tags
+func (c *Cache) CompareAndSet(key string, version uint64, v interface{}, expiration time.Duration) error {
+	return c.CompareAndSetContext(context.Background(), key, version, v, expiration)
+}
+
+// CompareAndSetContext is like CompareAndSet but carries ctx down to the
+// storage
+func (c *Cache) CompareAndSetContext(ctx context.Context, key string, version uint64, v interface{}, expiration time.Duration) error {
+	cs, ok := c.casStorage()
+	if !ok {
+		return ErrNotSupported
+	}
+
+	s, it := cs.(Storage), c.newItem(key, v, expiration)
+	if _, ok := c.tagger.(TagVersioner); ok {
+		tags, err := c.tagVersions(ctx, cs, key, version)
+		if err != nil {
+			return err
+		}
+		it.Tags = tags
+	}
+	val, err := c.value(s, it)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = cs.CompareAndWrite(ctx, c.NsKey(key), version, val, it.ttl())
+	c.observe(s, OpWrite, time.Since(start), 1, 0, sizeOf(val), err)
+	if err != nil {
+		return err
+	}
+	return c.delExcept(ctx, s, key)
+}
+
+// tagVersions returns the versions of the tags recorded by the value of
+// key, which has to still have the given version
+func (c *Cache) tagVersions(ctx context.Context, cs CASStorage, key string, version uint64) (map[string]uint64, error) {
+	v, current, err := cs.ReadVersion(ctx, c.NsKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if current != version {
+		return nil, ErrVersionMismatch
+	}
+	it, err := c.item(v)
+	if err != nil {
+		return nil, err
+	}
+	return it.Tags, nil
+}
+
+// casStorage returns the lowest priority storage implementing CASStorage
+func (c *Cache) casStorage() (CASStorage, bool) {
+	s, ok := c.shared(func(s Storage) bool {
+		_, ok := s.(CASStorage)
+		return ok
+	})
+	if !ok {
+		return nil, false
+	}
+	return s.(CASStorage), true
+}
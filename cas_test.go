@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	redisClient "github.com/redis/go-redis/v9"
+	"gotest.tools/assert"
+)
+
+func TestCache_CompareAndSet(t *testing.T) {
+	storages := map[string]func(t *testing.T) Storage{
+		"memcached": func(t *testing.T) Storage { return Memcached(memcached(t)) },
+		"redis": func(t *testing.T) Storage {
+			return Redis(&redisClient.Options{Addr: miniRedis(t).Addr()})
+		},
+		"in memory": func(t *testing.T) Storage { return InMemory() },
+	}
+
+	for name, storage := range storages {
+		t.Run(name, func(t *testing.T) {
+			c := New(WithStorage(storage(t)))
+
+			var v int
+			_, err := c.GetWithVersion("key1", &v)
+			assert.Equal(t, ErrKeyNotExist, err)
+			assert.Equal(t, ErrKeyNotExist, c.CompareAndSet("key1", 1, 1, 0))
+
+			assert.NilError(t, c.Set("key1", 1, time.Minute))
+			version, err := c.GetWithVersion("key1", &v)
+			assert.NilError(t, err)
+			assert.Equal(t, 1, v)
+
+			assert.NilError(t, c.CompareAndSet("key1", version, 2, time.Minute))
+			assert.Equal(t, ErrVersionMismatch, c.CompareAndSet("key1", version, 3, time.Minute))
+
+			assert.NilError(t, c.Get("key1", &v))
+			assert.Equal(t, 2, v)
+
+			// plain writes change the version too
+			version, err = c.GetWithVersion("key1", &v)
+			assert.NilError(t, err)
+			assert.NilError(t, c.Set("key1", 2, time.Minute))
+			assert.Equal(t, ErrVersionMismatch, c.CompareAndSet("key1", version, 3, time.Minute))
+		})
+	}
+}
+
+func TestCache_CompareAndSetConcurrently(t *testing.T) {
+	c := New(WithStorage(Memcached(memcached(t))))
+	assert.NilError(t, c.Set("counter", 0, 0))
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				for {
+					var n int
+					version, err := c.GetWithVersion("counter", &n)
+					if err == nil {
+						err = c.CompareAndSet("counter", version, n+1, 0)
+					}
+					if err == nil {
+						break
+					}
+					if err != ErrVersionMismatch {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	var n int
+	assert.NilError(t, c.Get("counter", &n))
+	assert.Equal(t, 100, n)
+}
+
+func TestCache_CompareAndSetTiers(t *testing.T) {
+	var (
+		high   = InMemory()
+		medium = InMemory()
+	)
+	c := New(WithHighPriorityStorage(high), WithMediumPriorityStorage(medium))
+	assert.NilError(t, c.Set("key1", 1, 0))
+
+	var v int
+	version, err := c.GetWithVersion("key1", &v)
+	assert.NilError(t, err)
+	assert.NilError(t, c.CompareAndSet("key1", version, 2, 0))
+
+	_, err = high.Read("go:cache:key1")
+	assert.Equal(t, ErrKeyNotExist, err)
+	_, err = medium.Read("go:cache:key1")
+	assert.NilError(t, err)
+
+	c = New(WithStorage(Filesystem("./cache")))
+	_, err = c.GetWithVersion("key1", &v)
+	assert.Equal(t, ErrNotSupported, err)
+}
+
+func TestCache_CompareAndSetVersionTagger(t *testing.T) {
+	c := New(WithStorage(Memcached(memcached(t))), WithTagger(VersionTagger("go:cache:tagger")))
+	assert.NilError(t, c.Set("key1", 1, 0, "tag1"))
+
+	var v int
+	version, err := c.GetWithVersion("key1", &v)
+	assert.NilError(t, err)
+	assert.NilError(t, c.CompareAndSet("key1", version, 2, 0))
+
+	assert.NilError(t, c.Get("key1", &v))
+	assert.Equal(t, 2, v)
+	assert.NilError(t, c.DelByTag("tag1"))
+	assert.ErrorContains(t, c.Get("key1", &v), ErrKeyNotExist.Error())
+}
+
+func TestCache_CompareAndSetShared(t *testing.T) {
+	var (
+		m = miniRedis(t)
+		c = []*Cache{
+			New(WithStorage(InMemory(), Redis(&redisClient.Options{Addr: m.Addr()}))),
+			New(WithStorage(InMemory(), Redis(&redisClient.Options{Addr: m.Addr()}))),
+		}
+	)
+	assert.NilError(t, c[0].Set("key1", 1, 0))
+
+	var v int
+	version0, err := c[0].GetWithVersion("key1", &v)
+	assert.NilError(t, err)
+	version1, err := c[1].GetWithVersion("key1", &v)
+	assert.NilError(t, err)
+	assert.Equal(t, version0, version1)
+
+	assert.NilError(t, c[0].CompareAndSet("key1", version0, 2, 0))
+	assert.Equal(t, ErrVersionMismatch, c[1].CompareAndSet("key1", version1, 3, 0))
+
+	assert.NilError(t, c[1].Get("key1", &v))
+	assert.Equal(t, 2, v)
+}
@@ -0,0 +1,241 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrNotProtoMessage indicates that the value can't be handled by the
+// protobuf codec
+var ErrNotProtoMessage = errors.New("value is not a proto.Message")
+
+// Codec marshals cached values into bytes and back
+type Codec interface {
+	// Name identifies the codec in the stored envelope so that the
+	// value can be decoded even if the cache is configured with
+	// another codec at the time of reading
+	Name() string
+
+	// Marshal encodes v
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal decodes data into the value pointed to by v
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// JSON is a Codec encoding values with encoding/json
+	JSON Codec = jsonCodec{}
+
+	// Gob is a Codec encoding values with encoding/gob
+	Gob Codec = gobCodec{}
+
+	// MsgPack is a Codec encoding values with MessagePack
+	MsgPack Codec = msgpackCodec{}
+
+	// Protobuf is a Codec encoding proto.Message values
+	Protobuf Codec = protobufCodec{}
+)
+
+// codecs are the codecs values can always be decoded with
+var codecs = map[string]Codec{
+	JSON.Name():     JSON,
+	Gob.Name():      Gob,
+	MsgPack.Name():  MsgPack,
+	Protobuf.Name(): Protobuf,
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string { return "msgpack" }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string { return "protobuf" }
+
+func (protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, ErrNotProtoMessage
+	}
+	return proto.Marshal(m)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, m)
+	}
+
+	// v may point to a nil message pointer, e.g. when decoding
+	// into a *pb.Message variable
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Ptr {
+		return ErrNotProtoMessage
+	}
+	m, ok := reflect.New(rv.Elem().Type().Elem()).Interface().(proto.Message)
+	if !ok {
+		return ErrNotProtoMessage
+	}
+	if err := proto.Unmarshal(data, m); err != nil {
+		return err
+	}
+	rv.Elem().Set(reflect.ValueOf(m))
+	return nil
+}
+
+// encoded is a value read from a byte storage which has not been
+// decoded yet. It is decoded straight into the destination once
+// the destination is known
+type encoded struct {
+	codec Codec
+	data  []byte
+}
+
+// marshal returns byte slices as they are and JSON encodes anything
+// else. Byte storage use it to persist the values they're given
+func marshal(v interface{}) ([]byte, error) {
+	if b, ok := v.([]byte); ok {
+		return b, nil
+	}
+	return json.Marshal(v)
+}
+
+// decoder returns a function decoding cached values into out
+func decoder(out interface{}) func(v interface{}) error {
+	return func(v interface{}) error {
+		return decode(v, out)
+	}
+}
+
+// decode decodes v into out. Encoded values are unmarshaled with the
+// codec they have been encoded with, anything else is mapped over
+// with mapstructure
+func decode(v interface{}, out interface{}) error {
+	switch x := v.(type) {
+	case encoded:
+		return x.codec.Unmarshal(x.data, out)
+	case []interface{}:
+		if containsEncoded(x) {
+			return decodeSlice(x, out)
+		}
+	case map[string]interface{}:
+		for _, val := range x {
+			if _, ok := val.(encoded); ok {
+				return decodeMap(x, out)
+			}
+		}
+	}
+	return mapstructure.Decode(v, out)
+}
+
+func decodeSlice(values []interface{}, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("out must be a non-nil pointer")
+	}
+
+	var (
+		target = rv.Elem()
+		typ    = reflect.TypeOf((*interface{})(nil)).Elem()
+	)
+	if target.Kind() == reflect.Slice {
+		typ = target.Type().Elem()
+	}
+
+	slice := reflect.MakeSlice(reflect.SliceOf(typ), 0, len(values))
+	for _, v := range values {
+		p := reflect.New(typ)
+		if err := decode(v, p.Interface()); err != nil {
+			return err
+		}
+		slice = reflect.Append(slice, p.Elem())
+	}
+
+	if target.Kind() == reflect.Slice {
+		target.Set(slice)
+		return nil
+	}
+	return mapstructure.Decode(slice.Interface(), out)
+}
+
+func decodeMap(values map[string]interface{}, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("out must be a non-nil pointer")
+	}
+
+	var (
+		target = rv.Elem()
+		typ    = reflect.TypeOf((*interface{})(nil)).Elem()
+	)
+	if target.Kind() == reflect.Map && target.Type().Key().Kind() == reflect.String {
+		typ = target.Type().Elem()
+	}
+
+	m := reflect.MakeMapWithSize(reflect.MapOf(reflect.TypeOf(""), typ), len(values))
+	for key, v := range values {
+		p := reflect.New(typ)
+		if err := decode(v, p.Interface()); err != nil {
+			return err
+		}
+		m.SetMapIndex(reflect.ValueOf(key), p.Elem())
+	}
+
+	if target.Kind() == reflect.Map && target.Type() == m.Type() {
+		target.Set(m)
+		return nil
+	}
+	return mapstructure.Decode(m.Interface(), out)
+}
+
+func containsEncoded(values []interface{}) bool {
+	for _, v := range values {
+		if _, ok := v.(encoded); ok {
+			return true
+		}
+	}
+	return false
+}
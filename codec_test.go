@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"gotest.tools/assert"
+)
+
+// bytesMock is an in memory byte storage
+type bytesMock struct {
+	*InMem
+}
+
+func (bytesMock) StoresBytes() {}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	for _, codec := range []Codec{JSON, Gob, MsgPack} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			s := bytesMock{InMemory()}
+			c := New(WithStorage(s), WithCodec(codec), WithNamespace("go:test"))
+
+			now := time.Now().Round(0)
+			assert.NilError(t, c.Set("key1", User{City: "Yerevan", Email: "user@example.com"}, 0))
+			assert.NilError(t, c.Set("key2", now, 0))
+
+			b, ok := s.data["go:test:key1"].([]byte)
+			assert.Assert(t, ok)
+			assert.Assert(t, isEnvelope(b))
+
+			var u User
+			assert.NilError(t, c.Get("key1", &u))
+			assert.Equal(t, User{City: "Yerevan", Email: "user@example.com"}, u)
+
+			var tm time.Time
+			assert.NilError(t, c.Get("key2", &tm))
+			assert.Assert(t, now.Equal(tm))
+		})
+	}
+}
+
+func TestCodec_Protobuf(t *testing.T) {
+	c := New(WithStorage(bytesMock{InMemory()}), WithCodec(Protobuf))
+	assert.NilError(t, c.Set("key1", wrapperspb.String("abc"), 0))
+	assert.ErrorContains(t, c.Set("key2", "abc", 0), ErrNotProtoMessage.Error())
+
+	var v *wrapperspb.StringValue
+	assert.NilError(t, c.Get("key1", &v))
+	assert.Assert(t, proto.Equal(wrapperspb.String("abc"), v))
+
+	v2 := &wrapperspb.StringValue{}
+	assert.NilError(t, c.Get("key1", v2))
+	assert.Equal(t, "abc", v2.GetValue())
+}
+
+func TestCodec_MixedCodecs(t *testing.T) {
+	s := bytesMock{InMemory()}
+	New(WithStorage(s), WithCodec(Gob)).Set("key1", User{City: "Yerevan"}, 0, "tag1")
+	New(WithStorage(s), WithCodec(JSON)).Set("key2", User{City: "Gyumri"}, 0, "tag1")
+
+	c := New(WithStorage(s), WithCodec(MsgPack))
+	c.Set("key3", User{City: "Vanadzor"}, 0, "tag1")
+
+	var users []User
+	assert.NilError(t, c.ByTag("tag1", &users))
+	assert.DeepEqual(t, []User{{City: "Yerevan"}, {City: "Gyumri"}, {City: "Vanadzor"}}, users)
+
+	var m map[string]User
+	assert.NilError(t, c.GetMulti([]string{"key1", "key2", "key3"}, &m))
+	assert.DeepEqual(t, map[string]User{
+		"key1": {City: "Yerevan"},
+		"key2": {City: "Gyumri"},
+		"key3": {City: "Vanadzor"},
+	}, m)
+}
+
+func TestCodec_LegacyJSON(t *testing.T) {
+	s := bytesMock{InMemory()}
+	b, _ := json.Marshal(item{Key: "key1", Val: "val1", Created: time.Now()})
+	s.Write("go:cache:key1", b, 0)
+
+	var v string
+	assert.NilError(t, New(WithStorage(s), WithCodec(Gob)).Get("key1", &v))
+	assert.Equal(t, "val1", v)
+}
+
+func TestCodec_UnknownCodec(t *testing.T) {
+	s := bytesMock{InMemory()}
+	b, _ := encodeItem(encoding{codec: fakeCodec{JSON}}, item{Key: "key1", Val: "val1", Created: time.Now()})
+	s.Write("go:cache:key1", b, 0)
+
+	var v string
+	assert.ErrorContains(t, New(WithStorage(s)).Get("key1", &v), `unknown codec "fake"`)
+	assert.NilError(t, New(WithStorage(s), WithCodec(fakeCodec{JSON})).Get("key1", &v))
+	assert.Equal(t, "val1", v)
+}
+
+type fakeCodec struct {
+	Codec
+}
+
+func (fakeCodec) Name() string { return "fake" }
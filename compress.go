@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compressor compresses encoded values before they're written to byte
+// storage. It is configured with WithCompression
+type Compressor interface {
+	// Name identifies the compressor in the stored envelope
+	Name() string
+
+	// Compress compresses data
+	Compress(data []byte) ([]byte, error)
+
+	// Decompress decompresses data compressed with Compress
+	Decompress(data []byte) ([]byte, error)
+}
+
+var (
+	// Gzip compresses values with gzip
+	Gzip Compressor = gzipCompressor{}
+
+	// Zstd compresses values with Zstandard
+	Zstd Compressor = &zstdCompressor{}
+
+	// Snappy compresses values with Snappy
+	Snappy Compressor = snappyCompressor{}
+)
+
+// compressors are the compressors values can always be decompressed with
+var compressors = map[string]Compressor{
+	Gzip.Name():   Gzip,
+	Zstd.Name():   Zstd,
+	Snappy.Name(): Snappy,
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Name() string { return "gzip" }
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// zstdCompressor lazily creates a single encoder and decoder which
+// are safe for concurrent use
+type zstdCompressor struct {
+	once    sync.Once
+	err     error
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+func (*zstdCompressor) Name() string { return "zstd" }
+
+func (z *zstdCompressor) init() error {
+	z.once.Do(func() {
+		if z.encoder, z.err = zstd.NewWriter(nil); z.err != nil {
+			return
+		}
+		z.decoder, z.err = zstd.NewReader(nil)
+	})
+	return z.err
+}
+
+func (z *zstdCompressor) Compress(data []byte) ([]byte, error) {
+	if err := z.init(); err != nil {
+		return nil, err
+	}
+	return z.encoder.EncodeAll(data, nil), nil
+}
+
+func (z *zstdCompressor) Decompress(data []byte) ([]byte, error) {
+	if err := z.init(); err != nil {
+		return nil, err
+	}
+	return z.decoder.DecodeAll(data, nil)
+}
+
+type snappyCompressor struct{}
+
+func (snappyCompressor) Name() string { return "snappy" }
+
+func (snappyCompressor) Compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+func (snappyCompressor) Decompress(data []byte) ([]byte, error) {
+	return snappy.Decode(nil, data)
+}
+
+func lookupCompressor(name string, known ...Compressor) Compressor {
+	for _, compressor := range known {
+		if compressor != nil && compressor.Name() == name {
+			return compressor
+		}
+	}
+	return compressors[name]
+}
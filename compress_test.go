@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func compression(t *testing.T, b []byte) string {
+	n, size := binary.Uvarint(b[1:])
+	var e envelope
+	assert.NilError(t, json.Unmarshal(b[1+size:1+size+int(n)], &e))
+	return e.Compression
+}
+
+func TestCompression(t *testing.T) {
+	large := strings.Repeat("go-cache ", 1024)
+
+	for _, compressor := range []Compressor{Gzip, Zstd, Snappy} {
+		t.Run(compressor.Name(), func(t *testing.T) {
+			s := bytesMock{InMemory()}
+			c := New(WithStorage(s), WithCompression(compressor, 512), WithNamespace("go:test"))
+
+			assert.NilError(t, c.Set("large", large, 0))
+			assert.NilError(t, c.Set("small", "abc", 0))
+
+			b := s.data["go:test:large"].([]byte)
+			assert.Equal(t, compressor.Name(), compression(t, b))
+			assert.Assert(t, len(b) < len(large))
+			assert.Equal(t, "", compression(t, s.data["go:test:small"].([]byte)))
+
+			var v string
+			assert.NilError(t, c.Get("large", &v))
+			assert.Equal(t, large, v)
+			assert.NilError(t, c.Get("small", &v))
+			assert.Equal(t, "abc", v)
+
+			// values can be read without compression configured
+			assert.NilError(t, New(WithStorage(s), WithNamespace("go:test")).Get("large", &v))
+			assert.Equal(t, large, v)
+		})
+	}
+}
+
+func TestCompression_Uncompressed(t *testing.T) {
+	s := bytesMock{InMemory()}
+	large := strings.Repeat("go-cache ", 1024)
+	New(WithStorage(s)).Set("key1", large, 0)
+
+	var v string
+	assert.NilError(t, New(WithStorage(s), WithCompression(Zstd, 0)).Get("key1", &v))
+	assert.Equal(t, large, v)
+}
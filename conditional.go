@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrKeyExists indicates that the key already exists in the storage
+var ErrKeyExists = errors.New("key already exists")
+
+// Add stores the value for the given key like Set unless the key exists,
+// failing with ErrKeyExists otherwise. The key is checked and written in
+// the lowest priority storage implementing ConditionalStorage and deleted
+// from the other storage, ErrNotSupported is returned if there is none
+func (c *Cache) Add(key string, v interface{}, expiration time.Duration, tags ...string) error {
+	return c.AddContext(context.Background(), key, v, expiration, tags...)
+}
+
+// AddContext is like Add but carries ctx down to the storage
+func (c *Cache) AddContext(ctx context.Context, key string, v interface{}, expiration time.Duration, tags ...string) error {
+	return c.writeIf(ctx, key, v, expiration, tags, func(cs ConditionalStorage, nsKey string, v interface{}, ttl time.Duration) error {
+		return cs.Add(ctx, nsKey, v, ttl)
+	})
+}
+
+// Replace stores the value for the given key like Set if the key exists,
+// failing with ErrKeyNotExist otherwise. The key is checked and written
+// the same way as by Add
+func (c *Cache) Replace(key string, v interface{}, expiration time.Duration, tags ...string) error {
+	return c.ReplaceContext(context.Background(), key, v, expiration, tags...)
+}
+
+// ReplaceContext is like Replace but carries ctx down to the storage
+func (c *Cache) ReplaceContext(ctx context.Context, key string, v interface{}, expiration time.Duration, tags ...string) error {
+	return c.writeIf(ctx, key, v, expiration, tags, func(cs ConditionalStorage, nsKey string, v interface{}, ttl time.Duration) error {
+		return cs.Replace(ctx, nsKey, v, ttl)
+	})
+}
+
+func (c *Cache) writeIf(ctx context.Context, key string, v interface{}, expiration time.Duration, tags []string, fn func(cs ConditionalStorage, nsKey string, v interface{}, ttl time.Duration) error) error {
+	s, ok := c.shared(func(s Storage) bool {
+		_, ok := s.(ConditionalStorage)
+		return ok
+	})
+	if !ok {
+		return ErrNotSupported
+	}
+
+	it := c.newItem(key, v, expiration)
+	err := c.store(ctx, s, key, it, tags, func(nsKey string, v interface{}) error {
+		return fn(s.(ConditionalStorage), nsKey, v, it.ttl())
+	})
+	if err != nil {
+		return err
+	}
+	return c.delExcept(ctx, s, key)
+}
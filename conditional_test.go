@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	redisClient "github.com/redis/go-redis/v9"
+	"gotest.tools/assert"
+)
+
+func TestCache_AddReplace(t *testing.T) {
+	storages := map[string]func(t *testing.T) Storage{
+		"memcached": func(t *testing.T) Storage { return Memcached(memcached(t)) },
+		"redis": func(t *testing.T) Storage {
+			return Redis(&redisClient.Options{Addr: miniRedis(t).Addr()})
+		},
+		"in memory":  func(t *testing.T) Storage { return InMemory() },
+		"filesystem": func(t *testing.T) Storage { return Filesystem(t.TempDir()) },
+	}
+
+	for name, storage := range storages {
+		t.Run(name, func(t *testing.T) {
+			c := New(WithStorage(storage(t)))
+
+			assert.Equal(t, ErrKeyNotExist, c.Replace("key1", 1, time.Minute))
+			assert.NilError(t, c.Add("key1", 1, time.Minute, "tag1"))
+			assert.Equal(t, ErrKeyExists, c.Add("key1", 2, time.Minute))
+
+			var v int
+			assert.NilError(t, c.Get("key1", &v))
+			assert.Equal(t, 1, v)
+
+			var keys []int
+			assert.NilError(t, c.ByTag("tag1", &keys))
+			assert.DeepEqual(t, []int{1}, keys)
+
+			assert.NilError(t, c.Replace("key1", 3, time.Minute))
+			assert.NilError(t, c.Get("key1", &v))
+			assert.Equal(t, 3, v)
+
+			assert.NilError(t, c.Del("key1"))
+			assert.NilError(t, c.Add("key1", 4, time.Minute))
+		})
+	}
+}
+
+func TestCache_AddExpired(t *testing.T) {
+	c := New(WithStorage(Filesystem(t.TempDir())))
+
+	assert.NilError(t, c.Add("key1", 1, 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	assert.NilError(t, c.Add("key1", 2, time.Minute))
+}
+
+func TestCache_AddTiers(t *testing.T) {
+	var (
+		high   = InMemory()
+		medium = InMemory()
+	)
+	c := New(WithHighPriorityStorage(high), WithMediumPriorityStorage(medium))
+	assert.NilError(t, high.Write("go:cache:key1", item{Key: "key1", Val: 1}, 0))
+
+	assert.NilError(t, c.Add("key1", 2, 0))
+	_, err := high.Read("go:cache:key1")
+	assert.Equal(t, ErrKeyNotExist, err)
+	_, err = medium.Read("go:cache:key1")
+	assert.NilError(t, err)
+	assert.Equal(t, ErrKeyExists, c.Add("key1", 3, 0))
+
+	c = New(WithStorage(Encrypted(InMemory(), nil)))
+	assert.Equal(t, ErrNotSupported, c.Add("key1", 1, 0))
+}
+
+func TestCache_AddShared(t *testing.T) {
+	var (
+		m = miniRedis(t)
+		c = []*Cache{
+			New(WithStorage(InMemory(), Redis(&redisClient.Options{Addr: m.Addr()}))),
+			New(WithStorage(InMemory(), Redis(&redisClient.Options{Addr: m.Addr()}))),
+		}
+	)
+
+	assert.NilError(t, c[0].Add("key1", 1, 0))
+	assert.Equal(t, ErrKeyExists, c[1].Add("key1", 2, 0))
+	assert.Assert(t, m.Exists("go:cache:key1"))
+
+	assert.NilError(t, c[1].Replace("key1", 3, 0))
+	var v int
+	assert.NilError(t, c[0].Get("key1", &v))
+	assert.Equal(t, 3, v)
+}
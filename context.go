@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// write writes to the storage passing ctx along if the storage
+// implements ContextStorage
+func write(ctx context.Context, s Storage, key string, v interface{}, ttl time.Duration) error {
+	if cs, ok := s.(ContextStorage); ok {
+		return cs.WriteContext(ctx, key, v, ttl)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Write(key, v, ttl)
+}
+
+// read reads from the storage passing ctx along if the storage
+// implements ContextStorage
+func read(ctx context.Context, s Storage, key string) (interface{}, error) {
+	if cs, ok := s.(ContextStorage); ok {
+		return cs.ReadContext(ctx, key)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Read(key)
+}
+
+// remove deletes from the storage passing ctx along if the storage
+// implements ContextStorage
+func remove(ctx context.Context, s Storage, key string) error {
+	if cs, ok := s.(ContextStorage); ok {
+		return cs.DeleteContext(ctx, key)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Delete(key)
+}
+
+// flush flushes the storage passing ctx along if the storage
+// implements ContextStorage
+func flush(ctx context.Context, s Storage) error {
+	if cs, ok := s.(ContextStorage); ok {
+		return cs.FlushContext(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Flush()
+}
+
+// bound binds a storage to ctx so that it can be handed to code which
+// only knows about Storage, e.g. a Tagger
+type bound struct {
+	ctx context.Context
+	s   Storage
+}
+
+func withContext(ctx context.Context, s Storage) Storage {
+	if b, ok := s.(bound); ok {
+		s = b.s
+	}
+	return bound{ctx: ctx, s: s}
+}
+
+func (b bound) Write(key string, v interface{}, ttl time.Duration) error {
+	return write(b.ctx, b.s, key, v, ttl)
+}
+
+func (b bound) Read(key string) (interface{}, error) {
+	return read(b.ctx, b.s, key)
+}
+
+func (b bound) Delete(key string) error {
+	return remove(b.ctx, b.s, key)
+}
+
+func (b bound) Flush() error {
+	return flush(b.ctx, b.s)
+}
+
+func (b bound) ReadMulti(_ context.Context, keys ...string) (map[string]interface{}, error) {
+	return readMulti(b.ctx, b.s, keys...)
+}
+
+func (b bound) WriteMulti(_ context.Context, values map[string]interface{}, ttl time.Duration) error {
+	return writeMulti(b.ctx, b.s, values, ttl)
+}
+
+func (b bound) DeleteMulti(_ context.Context, keys ...string) error {
+	return removeMulti(b.ctx, b.s, keys...)
+}
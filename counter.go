@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotCounter indicates that the key holds a value which is not a
+// counter, e.g. one stored with Set
+var ErrNotCounter = errors.New("value is not a counter")
+
+// Incr adds delta to the counter stored for the given key and returns its
+// new value. A missing counter is created with the value delta and the
+// given expiration, an existing one keeps its expiration. Counters are
+// stored as plain integers rather than encoded values, so they can be
+// read with Get but keys stored with Set can't be incremented and fail
+// with ErrNotCounter.
+//
+// The counter is updated atomically in the lowest priority storage
+// implementing CounterStorage and deleted from the other storage, and
+// ErrNotSupported is returned if there is none. Get doesn't copy counters
+// into higher priority storage, so they're always read where they're
+// updated
+func (c *Cache) Incr(key string, delta int64, expiration time.Duration) (int64, error) {
+	return c.incr(context.Background(), key, delta, expiration)
+}
+
+// IncrContext is like Incr but carries ctx down to the storage
+func (c *Cache) IncrContext(ctx context.Context, key string, delta int64, expiration time.Duration) (int64, error) {
+	return c.incr(ctx, key, delta, expiration)
+}
+
+// Decr subtracts delta from the counter stored for the given key the same
+// way Incr adds to it. Counters on memcached don't go below zero
+func (c *Cache) Decr(key string, delta int64, expiration time.Duration) (int64, error) {
+	return c.incr(context.Background(), key, -delta, expiration)
+}
+
+// DecrContext is like Decr but carries ctx down to the storage
+func (c *Cache) DecrContext(ctx context.Context, key string, delta int64, expiration time.Duration) (int64, error) {
+	return c.incr(ctx, key, -delta, expiration)
+}
+
+func (c *Cache) incr(ctx context.Context, key string, delta int64, expiration time.Duration) (int64, error) {
+	s, ok := c.shared(func(s Storage) bool {
+		_, ok := s.(CounterStorage)
+		return ok
+	})
+	if !ok {
+		return 0, ErrNotSupported
+	}
+
+	start := time.Now()
+	n, err := s.(CounterStorage).Incr(ctx, c.NsKey(key), delta, expiration)
+	c.observe(s, OpWrite, time.Since(start), 1, 0, 0, err)
+	if err != nil {
+		return 0, err
+	}
+	return n, c.delExcept(ctx, s, key)
+}
+
+// incr adds delta to the counter at key, atomically if the storage
+// implements CounterStorage and with a read and a write otherwise
+func incr(s Storage, key string, delta int64, ttl time.Duration) (int64, error) {
+	ctx := context.Background()
+	if b, ok := s.(bound); ok {
+		ctx, s = b.ctx, b.s
+	}
+	if cs, ok := s.(CounterStorage); ok {
+		return cs.Incr(ctx, key, delta, ttl)
+	}
+
+	v, err := read(ctx, s, key)
+	if err != nil && err != ErrKeyNotExist {
+		return 0, err
+	}
+	var n int64
+	if err == nil {
+		var ok bool
+		if n, ok = counter(v); !ok {
+			return 0, ErrNotCounter
+		}
+		// the expiration of the counter isn't known, so it's kept forever
+		ttl = 0
+	}
+	n += delta
+	return n, write(ctx, s, key, []byte(strconv.FormatInt(n, 10)), ttl)
+}
+
+// counter returns the value of a counter as stored by any storage
+func counter(v interface{}) (int64, bool) {
+	var s string
+	switch x := v.(type) {
+	case int64:
+		return x, true
+	case []byte:
+		s = string(x)
+	case string:
+		s = x
+	default:
+		return 0, false
+	}
+
+	// memcached may pad decremented counters with spaces
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	return n, err == nil
+}
@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	redisClient "github.com/redis/go-redis/v9"
+	"gotest.tools/assert"
+)
+
+func TestCache_Incr(t *testing.T) {
+	storages := map[string]func(t *testing.T) Storage{
+		"memcached": func(t *testing.T) Storage { return Memcached(memcached(t)) },
+		"redis": func(t *testing.T) Storage {
+			return Redis(&redisClient.Options{Addr: miniRedis(t).Addr()})
+		},
+		"in memory":  func(t *testing.T) Storage { return InMemory() },
+		"filesystem": func(t *testing.T) Storage { return Filesystem(t.TempDir()) },
+	}
+
+	for name, storage := range storages {
+		t.Run(name, func(t *testing.T) {
+			c := New(WithStorage(storage(t)))
+
+			n, err := c.Incr("views", 5, time.Minute)
+			assert.NilError(t, err)
+			assert.Equal(t, int64(5), n)
+
+			n, err = c.Incr("views", 2, 0)
+			assert.NilError(t, err)
+			assert.Equal(t, int64(7), n)
+
+			n, err = c.Decr("views", 3, 0)
+			assert.NilError(t, err)
+			assert.Equal(t, int64(4), n)
+
+			var v int
+			assert.NilError(t, c.Get("views", &v))
+			assert.Equal(t, 4, v)
+
+			assert.NilError(t, c.Set("key1", 1, 0))
+			_, err = c.Incr("key1", 1, 0)
+			assert.Equal(t, ErrNotCounter, err)
+		})
+	}
+}
+
+func TestCache_IncrConcurrently(t *testing.T) {
+	storages := map[string]Storage{
+		"in memory":  InMemory(),
+		"filesystem": Filesystem(t.TempDir()),
+	}
+
+	for name, s := range storages {
+		t.Run(name, func(t *testing.T) {
+			c := New(WithStorage(s))
+
+			var wg sync.WaitGroup
+			for w := 0; w < 4; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < 25; i++ {
+						if _, err := c.Incr("counter", 1, 0); err != nil {
+							t.Error(err)
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			var n int
+			assert.NilError(t, c.Get("counter", &n))
+			assert.Equal(t, 100, n)
+		})
+	}
+}
+
+func TestInMemory_IncrKeepsExpiration(t *testing.T) {
+	s := InMemory()
+
+	_, err := s.Incr(context.Background(), "counter", 1, 10*time.Millisecond)
+	assert.NilError(t, err)
+	_, err = s.Incr(context.Background(), "counter", 1, 0)
+	assert.NilError(t, err)
+
+	time.Sleep(20 * time.Millisecond)
+	_, err = s.Read("counter")
+	assert.Equal(t, ErrKeyNotExist, err)
+}
+
+func TestCache_IncrNotSupported(t *testing.T) {
+	c := New(WithStorage(Encrypted(InMemory(), nil)))
+
+	_, err := c.Incr("counter", 1, 0)
+	assert.Equal(t, ErrNotSupported, err)
+}
+
+func TestCache_IncrShared(t *testing.T) {
+	var (
+		m = miniRedis(t)
+		c = []*Cache{
+			New(WithStorage(InMemory(), Redis(&redisClient.Options{Addr: m.Addr()}))),
+			New(WithStorage(InMemory(), Redis(&redisClient.Options{Addr: m.Addr()}))),
+		}
+	)
+
+	n, err := c[0].Incr("counter", 1, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, int64(1), n)
+	n, err = c[1].Incr("counter", 1, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	v, err := m.Get("go:cache:counter")
+	assert.NilError(t, err)
+	assert.Equal(t, "2", v)
+}
+
+func TestCache_IncrNotPropagated(t *testing.T) {
+	var (
+		m     = miniRedis(t)
+		high  = InMemory()
+		other = New(WithStorage(InMemory(), Redis(&redisClient.Options{Addr: m.Addr()})))
+	)
+	c := New(WithStorage(high, Redis(&redisClient.Options{Addr: m.Addr()})))
+
+	_, err := c.Incr("counter", 1, 50*time.Millisecond)
+	assert.NilError(t, err)
+
+	var v int
+	assert.NilError(t, c.Get("counter", &v))
+	assert.Equal(t, 1, v)
+	var values map[string]int
+	assert.NilError(t, c.GetMulti([]string{"counter"}, &values))
+	assert.Equal(t, 0, len(high.data))
+
+	// increments of other processes are seen
+	_, err = other.Incr("counter", 1, 0)
+	assert.NilError(t, err)
+	assert.NilError(t, c.Get("counter", &v))
+	assert.Equal(t, 2, v)
+
+	m.FastForward(100 * time.Millisecond)
+	assert.ErrorContains(t, c.Get("counter", &v), ErrKeyNotExist.Error())
+}
@@ -0,0 +1,256 @@
+package cache
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// encryptedMagic starts every value written by Encrypted
+const encryptedMagic byte = 0x01
+
+var (
+	// ErrNotEncrypted indicates that a value read through Encrypted
+	// storage has not been encrypted by it
+	ErrNotEncrypted = errors.New("value is not encrypted")
+
+	// ErrUnknownKey indicates that a value has been encrypted with a key
+	// which is not in the keyring
+	ErrUnknownKey = errors.New("value is encrypted with an unknown key")
+)
+
+// Keyring holds the AES keys used by Encrypted storage. Values are always
+// encrypted with the primary key and the id of the key is stored along
+// with the ciphertext, so after a rotation values encrypted with any key
+// still in the keyring can be decrypted
+type Keyring struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+	hash    []byte
+}
+
+// NewKeyring creates a keyring from AES-128, AES-192 or AES-256 keys by
+// their ids. New values are encrypted with the key identified by primary
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("primary key %q is not in the keyring", primary)
+	}
+
+	k := &Keyring{primary: primary, aeads: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if len(id) == 0 || len(id) > 255 {
+			return nil, fmt.Errorf("key id %q must be 1 to 255 bytes long", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %v", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %v", id, err)
+		}
+		k.aeads[id] = aead
+	}
+	return k, nil
+}
+
+// WithKeyHashing returns a copy of the keyring which additionally makes
+// Encrypted storage replace keys with their HMAC-SHA256 using secret, so
+// that keys do not leak into the storage either. The secret must not be
+// rotated, otherwise all the stored keys are lost
+func (k *Keyring) WithKeyHashing(secret []byte) *Keyring {
+	c := *k
+	c.hash = secret
+	return &c
+}
+
+func (k *Keyring) key(key string) string {
+	if k.hash == nil {
+		return key
+	}
+	mac := hmac.New(sha256.New, k.hash)
+	io.WriteString(mac, key)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// seal encrypts plaintext with the primary key. The stored key is used
+// as additional data so that values can't be swapped between keys
+func (k *Keyring) seal(key string, plaintext []byte) ([]byte, error) {
+	aead := k.aeads[k.primary]
+
+	b := make([]byte, 0, 2+len(k.primary)+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	b = append(b, encryptedMagic, byte(len(k.primary)))
+	b = append(b, k.primary...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	b = append(b, nonce...)
+	return aead.Seal(b, nonce, plaintext, []byte(key)), nil
+}
+
+func (k *Keyring) open(key string, b []byte) ([]byte, error) {
+	if len(b) < 2 || b[0] != encryptedMagic || len(b) < 2+int(b[1]) {
+		return nil, ErrNotEncrypted
+	}
+
+	var (
+		id   = string(b[2 : 2+int(b[1])])
+		rest = b[2+int(b[1]):]
+	)
+	aead, ok := k.aeads[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, ErrNotEncrypted
+	}
+	return aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], []byte(key))
+}
+
+// encrypted is a Storage wrapper encrypting values with AES-GCM
+type encrypted struct {
+	s       Storage
+	keyring *Keyring
+}
+
+// Encrypted wraps the given storage so that values are encrypted with
+// AES-GCM before they are written to it and authenticated and decrypted
+// when read from it. It can wrap any Storage and be passed to
+// cache.New(WithStorage(...)) in its place
+func Encrypted(s Storage, keyring *Keyring) Storage {
+	return encrypted{s: s, keyring: keyring}
+}
+
+func (e encrypted) StoresBytes() {}
+
+func (e encrypted) Write(key string, v interface{}, ttl time.Duration) error {
+	return e.WriteContext(context.Background(), key, v, ttl)
+}
+
+func (e encrypted) WriteContext(ctx context.Context, key string, v interface{}, ttl time.Duration) error {
+	key = e.keyring.key(key)
+	b, err := e.seal(key, v)
+	if err != nil {
+		return err
+	}
+	return write(ctx, e.s, key, b, ttl)
+}
+
+func (e encrypted) Read(key string) (interface{}, error) {
+	return e.ReadContext(context.Background(), key)
+}
+
+func (e encrypted) ReadContext(ctx context.Context, key string) (interface{}, error) {
+	key = e.keyring.key(key)
+	v, err := read(ctx, e.s, key)
+	if err != nil {
+		return nil, err
+	}
+	return e.open(key, v)
+}
+
+func (e encrypted) Delete(key string) error {
+	return e.DeleteContext(context.Background(), key)
+}
+
+func (e encrypted) DeleteContext(ctx context.Context, key string) error {
+	return remove(ctx, e.s, e.keyring.key(key))
+}
+
+func (e encrypted) Flush() error {
+	return e.FlushContext(context.Background())
+}
+
+func (e encrypted) FlushContext(ctx context.Context) error {
+	return flush(ctx, e.s)
+}
+
+func (e encrypted) withNamespace(ns string) Storage {
+	if n, ok := e.s.(namespacer); ok {
+		e.s = n.withNamespace(ns)
+	}
+	return e
+}
+
+func (e encrypted) ReadMulti(ctx context.Context, keys ...string) (map[string]interface{}, error) {
+	var (
+		hashed = make([]string, len(keys))
+		byHash = make(map[string]string, len(keys))
+	)
+	for i, key := range keys {
+		hashed[i] = e.keyring.key(key)
+		byHash[hashed[i]] = key
+	}
+
+	values, err := readMulti(ctx, e.s, hashed...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]interface{}, len(values))
+	for h, v := range values {
+		b, err := e.open(h, v)
+		if err != nil {
+			return nil, err
+		}
+		result[byHash[h]] = b
+	}
+	return result, nil
+}
+
+func (e encrypted) WriteMulti(ctx context.Context, values map[string]interface{}, ttl time.Duration) error {
+	sealed := make(map[string]interface{}, len(values))
+	for key, v := range values {
+		key = e.keyring.key(key)
+		b, err := e.seal(key, v)
+		if err != nil {
+			return err
+		}
+		sealed[key] = b
+	}
+	return writeMulti(ctx, e.s, sealed, ttl)
+}
+
+func (e encrypted) DeleteMulti(ctx context.Context, keys ...string) error {
+	hashed := make([]string, len(keys))
+	for i, key := range keys {
+		hashed[i] = e.keyring.key(key)
+	}
+	return removeMulti(ctx, e.s, hashed...)
+}
+
+// Close closes the wrapped storage if it implements io.Closer
+func (e encrypted) Close() error {
+	if closer, ok := e.s.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (e encrypted) seal(key string, v interface{}) ([]byte, error) {
+	b, err := marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return e.keyring.seal(key, b)
+}
+
+func (e encrypted) open(key string, v interface{}) ([]byte, error) {
+	switch x := v.(type) {
+	case []byte:
+		return e.keyring.open(key, x)
+	case string:
+		return e.keyring.open(key, []byte(x))
+	}
+	return nil, ErrNotEncrypted
+}
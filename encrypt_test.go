@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"bytes"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+var (
+	testKey1 = bytes.Repeat([]byte{1}, 32)
+	testKey2 = bytes.Repeat([]byte{2}, 16)
+)
+
+func TestEncrypted_RoundTrip(t *testing.T) {
+	keyring, err := NewKeyring("k1", map[string][]byte{"k1": testKey1})
+	assert.NilError(t, err)
+
+	s := InMemory()
+	c := New(WithStorage(Encrypted(s, keyring)), WithNamespace("go:test"))
+	assert.NilError(t, c.Set("key1", User{City: "Yerevan", Email: "user@example.com"}, 0, "tag1"))
+
+	b, ok := s.data["go:test:key1"].([]byte)
+	assert.Assert(t, ok)
+	assert.Assert(t, !bytes.Contains(b, []byte("user@example.com")))
+
+	var u User
+	assert.NilError(t, c.Get("key1", &u))
+	assert.Equal(t, User{City: "Yerevan", Email: "user@example.com"}, u)
+
+	var users []User
+	assert.NilError(t, c.ByTag("tag1", &users))
+	assert.DeepEqual(t, []User{u}, users)
+
+	var m map[string]User
+	assert.NilError(t, c.GetMulti([]string{"key1", "key2"}, &m))
+	assert.DeepEqual(t, map[string]User{"key1": u}, m)
+}
+
+func TestEncrypted_KeyRotation(t *testing.T) {
+	s := InMemory()
+	old, err := NewKeyring("k1", map[string][]byte{"k1": testKey1})
+	assert.NilError(t, err)
+	assert.NilError(t, New(WithStorage(Encrypted(s, old))).Set("key1", "abc", 0))
+
+	rotated, err := NewKeyring("k2", map[string][]byte{"k1": testKey1, "k2": testKey2})
+	assert.NilError(t, err)
+	c := New(WithStorage(Encrypted(s, rotated)))
+	assert.NilError(t, c.Set("key2", "def", 0))
+
+	var v string
+	assert.NilError(t, c.Get("key1", &v))
+	assert.Equal(t, "abc", v)
+	assert.NilError(t, c.Get("key2", &v))
+	assert.Equal(t, "def", v)
+
+	assert.ErrorContains(t, New(WithStorage(Encrypted(s, old))).Get("key2", &v), ErrUnknownKey.Error())
+}
+
+func TestEncrypted_Tampering(t *testing.T) {
+	keyring, err := NewKeyring("k1", map[string][]byte{"k1": testKey1})
+	assert.NilError(t, err)
+
+	s := InMemory()
+	e := Encrypted(s, keyring)
+	assert.NilError(t, e.Write("key1", []byte("abc"), 0))
+
+	// ciphertext is bound to its key
+	s.data["key2"] = s.data["key1"]
+	_, err = e.Read("key2")
+	assert.ErrorContains(t, err, "authentication failed")
+
+	b := s.data["key1"].([]byte)
+	b[len(b)-1] ^= 0xff
+	_, err = e.Read("key1")
+	assert.ErrorContains(t, err, "authentication failed")
+
+	s.data["key3"] = []byte("abc")
+	_, err = e.Read("key3")
+	assert.Equal(t, ErrNotEncrypted, err)
+}
+
+func TestEncrypted_KeyHashing(t *testing.T) {
+	keyring, err := NewKeyring("k1", map[string][]byte{"k1": testKey1})
+	assert.NilError(t, err)
+
+	s := InMemory()
+	c := New(WithStorage(Encrypted(s, keyring.WithKeyHashing([]byte("secret")))))
+	assert.NilError(t, c.Set("key1", "abc", 0, "tag1"))
+
+	for key := range s.data {
+		assert.Assert(t, !bytes.Contains([]byte(key), []byte("key1")), key)
+		assert.Assert(t, !bytes.Contains([]byte(key), []byte("tag1")), key)
+	}
+
+	var v string
+	assert.NilError(t, c.Get("key1", &v))
+	assert.Equal(t, "abc", v)
+
+	assert.NilError(t, c.DelByTag("tag1"))
+	assert.ErrorContains(t, c.Get("key1", &v), ErrKeyNotExist.Error())
+}
+
+func TestNewKeyring(t *testing.T) {
+	_, err := NewKeyring("k1", map[string][]byte{"k2": testKey2})
+	assert.ErrorContains(t, err, "primary key")
+
+	_, err = NewKeyring("k1", map[string][]byte{"k1": []byte("short")})
+	assert.ErrorContains(t, err, "invalid key size")
+}
@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// envelopeMagic starts every envelope. JSON encoded items written
+// before codecs were introduced never start with it
+const envelopeMagic byte = 0x00
+
+// ErrMalformedEnvelope indicates that a stored value looked like an
+// envelope but could not be parsed
+var ErrMalformedEnvelope = errors.New("malformed envelope")
+
+// envelope is the header of an item written to a byte storage. The
+// stored bytes are the magic byte, the uvarint length of the JSON
+// encoded header, the header and the value encoded with the codec
+type envelope struct {
+	Codec       string `json:"codec"`
+	Compression string `json:"compression,omitempty"`
+	Item        item   `json:"item"`
+}
+
+// encoding describes how items are encoded. Values at least threshold
+// bytes long once encoded are compressed if there is a compressor
+type encoding struct {
+	codec      Codec
+	compressor Compressor
+	threshold  int
+}
+
+// encodeItem encodes the item with the given encoding. Values which
+// haven't been decoded since they've been read, e.g. propagated between
+// byte storage, keep their codec
+func encodeItem(enc encoding, it item) ([]byte, error) {
+	var (
+		payload []byte
+		err     error
+	)
+	if x, ok := it.Val.(encoded); ok {
+		enc.codec, payload = x.codec, x.data
+	} else if payload, err = enc.codec.Marshal(it.Val); err != nil {
+		return nil, err
+	}
+
+	e := envelope{Codec: enc.codec.Name()}
+	if enc.compressor != nil && len(payload) >= enc.threshold {
+		if payload, err = enc.compressor.Compress(payload); err != nil {
+			return nil, err
+		}
+		e.Compression = enc.compressor.Name()
+	}
+
+	it.Val = nil
+	e.Item = it
+	header, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 0, 1+binary.MaxVarintLen64+len(header)+len(payload))
+	b = append(b, envelopeMagic)
+	b = binary.AppendUvarint(b, uint64(len(header)))
+	b = append(b, header...)
+	return append(b, payload...), nil
+}
+
+// isEnvelope reports whether b has been written by encodeItem
+func isEnvelope(b []byte) bool {
+	return len(b) > 0 && b[0] == envelopeMagic
+}
+
+// decodeItem decodes an envelope written by encodeItem. The value is
+// decompressed but left encoded and is only decoded once the destination
+// is known. The codec and the compressor are looked up in the given
+// encoding first and the built-in ones next
+func decodeItem(enc encoding, b []byte) (item, error) {
+	n, size := binary.Uvarint(b[1:])
+	if size <= 0 || uint64(len(b)-1-size) < n {
+		return item{}, ErrMalformedEnvelope
+	}
+
+	var (
+		header  = b[1+size : 1+size+int(n)]
+		payload = b[1+size+int(n):]
+		e       envelope
+	)
+	if err := json.Unmarshal(header, &e); err != nil {
+		return item{}, ErrMalformedEnvelope
+	}
+
+	codec := lookupCodec(e.Codec, enc.codec)
+	if codec == nil {
+		return item{}, fmt.Errorf("unknown codec %q", e.Codec)
+	}
+
+	if e.Compression != "" {
+		compressor := lookupCompressor(e.Compression, enc.compressor)
+		if compressor == nil {
+			return item{}, fmt.Errorf("unknown compression %q", e.Compression)
+		}
+
+		var err error
+		if payload, err = compressor.Decompress(payload); err != nil {
+			return item{}, err
+		}
+	}
+
+	it := e.Item
+	it.Val = encoded{codec: codec, data: payload}
+	return it, nil
+}
+
+func lookupCodec(name string, known ...Codec) Codec {
+	for _, codec := range known {
+		if codec != nil && codec.Name() == name {
+			return codec
+		}
+	}
+	return codecs[name]
+}
@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"strings"
+	"sync"
+)
+
+// Reason is why an entry has left a storage
+type Reason int
+
+const (
+	// ReasonDeleted is for entries deleted with Del or DelByTag
+	ReasonDeleted Reason = iota
+
+	// ReasonExpired is for entries found expired when read or removed once
+	// expired by InMem
+	ReasonExpired
+
+	// ReasonEvicted is for entries evicted by a bounded storage, e.g.
+	// InMem
+	ReasonEvicted
+)
+
+// String returns the name of the reason, e.g. expired
+func (r Reason) String() string {
+	switch r {
+	case ReasonDeleted:
+		return "deleted"
+	case ReasonExpired:
+		return "expired"
+	case ReasonEvicted:
+		return "evicted"
+	}
+	return "unknown"
+}
+
+// Event reports an entry which has left a storage of a cache
+type Event struct {
+	Key string
+
+	// Storage is the name of the storage the entry has left, which is its
+	// type name, and Priority its priority
+	Storage  string
+	Priority Priority
+
+	Reason Reason
+
+	// Value is the value of the entry if it is known, which it is for
+	// expired and evicted entries but not for deleted ones. Values read
+	// from byte storage, or copied from them, are the bytes they have been
+	// encoded to with Codec, which is nil for any other value
+	Value interface{}
+	Codec Codec
+}
+
+// Decode decodes the value of the event into out, unmarshaling it with
+// the codec it has been encoded with if any
+func (e Event) Decode(out interface{}) error {
+	if b, ok := e.Value.([]byte); ok && e.Codec != nil {
+		return e.Codec.Unmarshal(b, out)
+	}
+	return decode(e.Value, out)
+}
+
+// hooks are the functions called with the events of a cache
+type hooks struct {
+	sync.RWMutex
+	fns map[Reason][]func(e Event)
+
+	// unwatch are the functions unregistering the cache from the storage
+	// reporting events to it
+	unwatch []func()
+}
+
+// add registers fn, reporting whether it's the first hook of the reason
+func (h *hooks) add(r Reason, fn func(e Event)) bool {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.fns == nil {
+		h.fns = make(map[Reason][]func(e Event))
+	}
+	h.fns[r] = append(h.fns[r], fn)
+	return len(h.fns[r]) == 1
+}
+
+// watching records a function unregistering the cache from a storage
+func (h *hooks) watching(unwatch func()) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.unwatch = append(h.unwatch, unwatch)
+}
+
+// close unregisters the cache from the storage reporting events to it
+func (h *hooks) close() {
+	h.Lock()
+	unwatch := h.unwatch
+	h.unwatch = nil
+	h.Unlock()
+
+	for _, fn := range unwatch {
+		fn()
+	}
+}
+
+func (h *hooks) get(r Reason) []func(e Event) {
+	h.RLock()
+	defer h.RUnlock()
+
+	return h.fns[r]
+}
+
+// OnDelete registers fn to be called for every key deleted from a storage
+// by Del or DelByTag, whether the key was stored or not. Keys of tags
+// invalidated by a TagVersioner aren't deleted and thus not reported.
+// Hooks are called synchronously by the deleting goroutine
+func (c *Cache) OnDelete(fn func(e Event)) {
+	c.hooks.add(ReasonDeleted, fn)
+}
+
+// OnExpire registers fn to be called for every entry found expired, past
+// the time it may be served stale, and deleted when read, or removed once
+// expired by a storage reporting it, i.e. InMem. Hooks are called
+// synchronously by the reading goroutine, or the janitor of the storage.
+// Storage report expiries to the cache from the first hook on until the
+// cache is closed
+func (c *Cache) OnExpire(fn func(e Event)) {
+	if c.hooks.add(ReasonExpired, fn) {
+		c.watch(ReasonExpired)
+	}
+}
+
+// OnEvict registers fn to be called for every entry evicted by a storage
+// of the cache bounded in size, i.e. InMem. Hooks are called synchronously
+// by the goroutine whose write has caused the eviction. Storage report
+// evictions to the cache from the first hook on until the cache is closed
+func (c *Cache) OnEvict(fn func(e Event)) {
+	if c.hooks.add(ReasonEvicted, fn) {
+		c.watch(ReasonEvicted)
+	}
+}
+
+// emit calls the hooks of the reason with an event for each of the keys
+// which have left s
+func (c *Cache) emit(s Storage, r Reason, v interface{}, keys ...string) {
+	fns := c.hooks.get(r)
+	if len(fns) == 0 {
+		return
+	}
+	if b, ok := s.(bound); ok {
+		s = b.s
+	}
+
+	var codec Codec
+	if x, ok := v.(encoded); ok {
+		v, codec = x.data, x.codec
+	}
+
+	for _, t := range c.tiers {
+		if !same(s, t.s) {
+			continue
+		}
+		for _, key := range keys {
+			e := Event{
+				Key:      key,
+				Storage:  t.stats.name,
+				Priority: t.stats.priority,
+				Reason:   r,
+				Value:    v,
+				Codec:    codec,
+			}
+			for _, fn := range fns {
+				fn(e)
+			}
+		}
+		return
+	}
+}
+
+// notifier is a storage reporting the entries leaving it on its own, e.g.
+// InMem
+type notifier interface {
+	OnEvict(fn func(key string, v interface{})) (remove func())
+	OnExpire(fn func(key string, v interface{})) (remove func())
+}
+
+// watch reports the items evicted or expired by the storage of the cache,
+// depending on r, to the hooks of r until the cache is closed. Other
+// entries, e.g. tag indexes and counters, aren't reported
+func (c *Cache) watch(r Reason) {
+	prefix := c.NsKey("")
+	for _, t := range c.tiers {
+		n, ok := t.s.(notifier)
+		if !ok {
+			continue
+		}
+
+		on := n.OnEvict
+		if r == ReasonExpired {
+			on = n.OnExpire
+		}
+		s := t.s
+		c.hooks.watching(on(func(key string, v interface{}) {
+			it, ok := v.(item)
+			if !ok || !strings.HasPrefix(key, prefix) {
+				return
+			}
+			c.emit(s, r, it.Val, strings.TrimPrefix(key, prefix))
+		}))
+	}
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+type events struct {
+	sync.Mutex
+	events []Event
+}
+
+func (e *events) record(ev Event) {
+	e.Lock()
+	defer e.Unlock()
+	e.events = append(e.events, ev)
+}
+
+func TestCache_OnDelete(t *testing.T) {
+	c := New(WithHighPriorityStorage(InMemory()), WithMediumPriorityStorage(InMemory()))
+	deleted := &events{}
+	c.OnDelete(deleted.record)
+
+	assert.NilError(t, c.Set("key1", 1, 0, "tag1"))
+	assert.NilError(t, c.Set("key2", 2, 0, "tag1"))
+	assert.NilError(t, c.Set("key3", 3, 0))
+
+	assert.NilError(t, c.Del("key3"))
+	assert.DeepEqual(t, []Event{
+		{Key: "key3", Storage: "inmem", Priority: PriorityHigh, Reason: ReasonDeleted},
+		{Key: "key3", Storage: "inmem", Priority: PriorityMedium, Reason: ReasonDeleted},
+	}, deleted.events)
+
+	deleted.events = nil
+	assert.NilError(t, c.DelByTag("tag1"))
+	keys := make(map[string]int)
+	for _, e := range deleted.events {
+		assert.Equal(t, ReasonDeleted, e.Reason)
+		keys[e.Key]++
+	}
+	assert.DeepEqual(t, map[string]int{"key1": 2, "key2": 2}, keys)
+}
+
+func TestCache_OnExpire(t *testing.T) {
+	c := New(WithStorage(InMemory()), WithStaleWhileRevalidate(50*time.Millisecond))
+	expired := &events{}
+	c.OnExpire(expired.record)
+
+	assert.NilError(t, c.Set("key1", "abc", 10*time.Millisecond))
+	assert.NilError(t, c.Set("key2", "def", time.Minute))
+	time.Sleep(20 * time.Millisecond)
+
+	// stale values are served rather than reported
+	var v string
+	assert.NilError(t, c.Get("key1", &v))
+	assert.Equal(t, 0, len(expired.events))
+
+	time.Sleep(50 * time.Millisecond)
+	assert.ErrorContains(t, c.Get("key1", &v), ErrKeyNotExist.Error())
+	assert.NilError(t, c.Get("key2", &v))
+	assert.DeepEqual(t, []Event{
+		{Key: "key1", Storage: "inmem", Priority: PriorityHigh, Reason: ReasonExpired, Value: "abc"},
+	}, expired.events)
+}
+
+func TestCache_OnExpireJanitor(t *testing.T) {
+	s := InMemory(WithJanitor(5 * time.Millisecond))
+	c := New(WithStorage(s))
+	expired := &events{}
+	c.OnExpire(expired.record)
+	defer c.Close()
+
+	assert.NilError(t, c.Set("key1", "abc", 10*time.Millisecond))
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		expired.Lock()
+		n := len(expired.events)
+		expired.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	expired.Lock()
+	defer expired.Unlock()
+	assert.DeepEqual(t, []Event{
+		{Key: "key1", Storage: "inmem", Priority: PriorityHigh, Reason: ReasonExpired, Value: "abc"},
+	}, expired.events)
+}
+
+func TestCache_OnEvict(t *testing.T) {
+	s := InMemory(WithMaxEntries(2))
+	c := New(WithStorage(s))
+	evicted := &events{}
+
+	// the storage is only watched once there are hooks
+	assert.Assert(t, !s.watched.Load())
+	c.OnEvict(evicted.record)
+	c.OnEvict(func(Event) {})
+	assert.Equal(t, 1, len(s.onEvict))
+
+	assert.NilError(t, c.Set("key1", 1, 0))
+	assert.NilError(t, c.Set("key2", 2, 0))
+	assert.NilError(t, c.Set("key3", 3, 0))
+	_, err := c.Incr("counter", 1, 0)
+	assert.NilError(t, err)
+
+	// counters aren't reported
+	assert.DeepEqual(t, []Event{
+		{Key: "key1", Storage: "inmem", Priority: PriorityHigh, Reason: ReasonEvicted, Value: 1},
+		{Key: "key2", Storage: "inmem", Priority: PriorityHigh, Reason: ReasonEvicted, Value: 2},
+	}, evicted.events)
+
+	assert.NilError(t, c.Close())
+	assert.Assert(t, !s.watched.Load())
+	assert.Equal(t, 0, len(s.onEvict))
+}
+
+func TestCache_EventDecode(t *testing.T) {
+	var (
+		high = InMemory(WithMaxEntries(1))
+		low  = Filesystem(t.TempDir())
+	)
+	assert.NilError(t, New(WithStorage(low)).Set("key1", "abc", 0))
+
+	c := New(WithHighPriorityStorage(high), WithMediumPriorityStorage(low))
+	evicted := &events{}
+	c.OnEvict(evicted.record)
+
+	// key1 is copied into the in memory storage still encoded
+	var v string
+	assert.NilError(t, c.Get("key1", &v))
+	assert.NilError(t, c.Set("key2", "def", 0))
+
+	assert.Equal(t, 1, len(evicted.events))
+	e := evicted.events[0]
+	assert.Equal(t, "key1", e.Key)
+	assert.Equal(t, JSON, e.Codec)
+	assert.DeepEqual(t, []byte(`"abc"`), e.Value)
+
+	v = ""
+	assert.NilError(t, e.Decode(&v))
+	assert.Equal(t, "abc", v)
+
+	var n int
+	assert.NilError(t, Event{Value: 1}.Decode(&n))
+	assert.Equal(t, 1, n)
+}
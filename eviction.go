@@ -0,0 +1,319 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"hash/maphash"
+)
+
+// EvictionPolicy selects the entries a size bounded InMem evicts
+type EvictionPolicy int
+
+const (
+	// LRU evicts the least recently used entry
+	LRU EvictionPolicy = iota
+
+	// LFU evicts the least frequently used entry, the least recently
+	// used one among equally frequent entries
+	LFU
+
+	// TinyLFU is W-TinyLFU: new entries enter a small LRU window and
+	// are only admitted into the main segmented LRU if they are
+	// estimated to be used more frequently than the entry they would
+	// replace
+	TinyLFU
+)
+
+// policy tracks the keys of a bounded InMem
+type policy interface {
+	// add records a newly written key
+	add(key string)
+
+	// access records a read or overwrite of key, which may or may not
+	// be stored
+	access(key string)
+
+	// remove forgets key
+	remove(key string)
+
+	// victim returns the key to evict next
+	victim() (string, bool)
+}
+
+func newPolicy(p EvictionPolicy, size int) policy {
+	switch p {
+	case LFU:
+		return newLFU()
+	case TinyLFU:
+		return newTinyLFU(size)
+	}
+	return newLRU()
+}
+
+type lru struct {
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func newLRU() *lru {
+	return &lru{ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (l *lru) add(key string) {
+	l.items[key] = l.ll.PushFront(key)
+}
+
+func (l *lru) access(key string) {
+	if e, ok := l.items[key]; ok {
+		l.ll.MoveToFront(e)
+	}
+}
+
+func (l *lru) remove(key string) {
+	if e, ok := l.items[key]; ok {
+		l.ll.Remove(e)
+		delete(l.items, key)
+	}
+}
+
+func (l *lru) victim() (string, bool) {
+	e := l.ll.Back()
+	if e == nil {
+		return "", false
+	}
+	return e.Value.(string), true
+}
+
+func (l *lru) newest() (string, bool) {
+	e := l.ll.Front()
+	if e == nil {
+		return "", false
+	}
+	return e.Value.(string), true
+}
+
+func (l *lru) contains(key string) bool {
+	_, ok := l.items[key]
+	return ok
+}
+
+func (l *lru) len() int {
+	return l.ll.Len()
+}
+
+type lfuEntry struct {
+	key   string
+	freq  int
+	tick  uint64
+	index int
+}
+
+type lfuHeap []*lfuEntry
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].tick < h[j].tick
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x interface{}) {
+	e := x.(*lfuEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+type lfu struct {
+	heap  lfuHeap
+	items map[string]*lfuEntry
+	tick  uint64
+}
+
+func newLFU() *lfu {
+	return &lfu{items: make(map[string]*lfuEntry)}
+}
+
+func (l *lfu) add(key string) {
+	l.tick++
+	e := &lfuEntry{key: key, freq: 1, tick: l.tick}
+	l.items[key] = e
+	heap.Push(&l.heap, e)
+}
+
+func (l *lfu) access(key string) {
+	e, ok := l.items[key]
+	if !ok {
+		return
+	}
+	l.tick++
+	e.freq++
+	e.tick = l.tick
+	heap.Fix(&l.heap, e.index)
+}
+
+func (l *lfu) remove(key string) {
+	e, ok := l.items[key]
+	if !ok {
+		return
+	}
+	heap.Remove(&l.heap, e.index)
+	delete(l.items, key)
+}
+
+func (l *lfu) victim() (string, bool) {
+	if len(l.heap) == 0 {
+		return "", false
+	}
+	return l.heap[0].key, true
+}
+
+// tinyLFU keeps new entries in a window LRU of about 1% of the entries.
+// Entries leaving the window enter the probation segment of the main
+// segmented LRU and are promoted to the protected segment once accessed
+// again. When an entry has to be evicted, the newest probation entry
+// competes with the oldest one and the one with the lower estimated
+// frequency is evicted
+type tinyLFU struct {
+	sketch    *sketch
+	window    *lru
+	probation *lru
+	protected *lru
+}
+
+func newTinyLFU(size int) *tinyLFU {
+	return &tinyLFU{
+		sketch:    newSketch(size),
+		window:    newLRU(),
+		probation: newLRU(),
+		protected: newLRU(),
+	}
+}
+
+func (t *tinyLFU) add(key string) {
+	t.sketch.increment(key)
+	t.window.add(key)
+
+	total := t.window.len() + t.probation.len() + t.protected.len()
+	for t.window.len() > 1 && t.window.len() > total/100 {
+		k, _ := t.window.victim()
+		t.window.remove(k)
+		t.probation.add(k)
+	}
+}
+
+func (t *tinyLFU) access(key string) {
+	t.sketch.increment(key)
+
+	switch {
+	case t.window.contains(key):
+		t.window.access(key)
+	case t.protected.contains(key):
+		t.protected.access(key)
+	case t.probation.contains(key):
+		t.probation.remove(key)
+		t.protected.add(key)
+
+		// keep the protected segment at 80% of the main segments
+		if max := (t.probation.len() + t.protected.len()) * 8 / 10; t.protected.len() > max {
+			k, _ := t.protected.victim()
+			t.protected.remove(k)
+			t.probation.add(k)
+		}
+	}
+}
+
+func (t *tinyLFU) remove(key string) {
+	t.window.remove(key)
+	t.probation.remove(key)
+	t.protected.remove(key)
+}
+
+func (t *tinyLFU) victim() (string, bool) {
+	victim, ok := t.probation.victim()
+	if !ok {
+		if victim, ok = t.protected.victim(); !ok {
+			return t.window.victim()
+		}
+		return victim, true
+	}
+
+	candidate, _ := t.probation.newest()
+	if candidate != victim && t.sketch.estimate(candidate) <= t.sketch.estimate(victim) {
+		return candidate, true
+	}
+	return victim, true
+}
+
+// sketch is a count-min sketch of 4 bit counters estimating the access
+// frequency of keys. Counters are halved periodically so that the
+// estimates follow changes of the workload
+type sketch struct {
+	rows  [4][]uint8
+	mask  uint64
+	seed  maphash.Seed
+	added int
+	reset int
+}
+
+func newSketch(size int) *sketch {
+	width := 64
+	for width < size {
+		width <<= 1
+	}
+
+	s := &sketch{mask: uint64(width - 1), seed: maphash.MakeSeed(), reset: 10 * width}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *sketch) index(h uint64, row int) uint64 {
+	return (h + uint64(row)*(h>>32|1)) & s.mask
+}
+
+func (s *sketch) increment(key string) {
+	h := maphash.String(s.seed, key)
+	for i := range s.rows {
+		if c := &s.rows[i][s.index(h, i)]; *c < 15 {
+			*c++
+		}
+	}
+
+	if s.added++; s.added >= s.reset {
+		for i := range s.rows {
+			for j := range s.rows[i] {
+				s.rows[i][j] >>= 1
+			}
+		}
+		s.added /= 2
+	}
+}
+
+func (s *sketch) estimate(key string) uint8 {
+	var (
+		h   = maphash.String(s.seed, key)
+		min = uint8(15)
+	)
+	for i := range s.rows {
+		if c := s.rows[i][s.index(h, i)]; c < min {
+			min = c
+		}
+	}
+	return min
+}
@@ -1,16 +1,29 @@
 package cache
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
 	"time"
 )
 
+// fsMagic starts the header written before the content of every file.
+// The header stores the absolute expiry of the file in unix nanoseconds,
+// zero meaning no expiry. Files without it never expire
+var fsMagic = []byte{0xfc, 'g', 'c', 1}
+
+const fsHeaderLen = 12
+
 // Fs is a struct implementing Storage interface using File System
 // as data storage
 type Fs struct {
@@ -31,8 +44,39 @@ func (f Fs) Write(key string, v interface{}, ttl time.Duration) error {
 		return err
 	}
 
-	b, _ := json.Marshal(map[string]interface{}{key: v})
-	return ioutil.WriteFile(path, b, 0600)
+	// envelopes are written as they are, any other value is wrapped so
+	// that it can't be mistaken for one
+	b, ok := v.([]byte)
+	switch {
+	case ok && isEnvelope(b):
+	case ok:
+		b, _ = json.Marshal(map[string]interface{}{key: b, key + fsBytesSuffix: true})
+	default:
+		b, _ = json.Marshal(map[string]interface{}{key: v})
+	}
+
+	return writeFile(path, b, expiresIn(ttl))
+}
+
+// fsBytesSuffix suffixes the key of the entry marking a wrapped value as
+// bytes, which JSON encodes as base64 strings
+const fsBytesSuffix = ":bytes"
+
+// writeFile writes b to the file at path after a header holding the
+// given expiry
+func writeFile(path string, b []byte, expires int64) error {
+	header := make([]byte, fsHeaderLen, fsHeaderLen+len(b))
+	copy(header, fsMagic)
+	binary.BigEndian.PutUint64(header[len(fsMagic):], uint64(expires))
+	return ioutil.WriteFile(path, append(header, b...), 0600)
+}
+
+// expiresIn returns the expiry of a file written now with the given ttl
+func expiresIn(ttl time.Duration) int64 {
+	if ttl > 0 {
+		return time.Now().Add(ttl).UnixNano()
+	}
+	return 0
 }
 
 // Read reads the cached content from the corresponding file
@@ -46,16 +90,31 @@ func (f Fs) Read(key string) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	if fileExpired(b, time.Now()) {
+		os.Remove(path)
+		return nil, ErrKeyNotExist
+	}
+	if bytes.HasPrefix(b, fsMagic) && len(b) >= fsHeaderLen {
+		b = b[fsHeaderLen:]
+	}
+	if isEnvelope(b) {
+		return b, nil
+	}
 
+	// counters are written unwrapped
 	var m map[string]interface{}
 	if err := json.Unmarshal(b, &m); err != nil {
-		return string(b), nil
+		return b, nil
 	}
 
 	v, has := m[key]
 	if !has {
 		return nil, ErrKeyNotExist
 	}
+	if m[key+fsBytesSuffix] == true {
+		s, _ := v.(string)
+		return base64.StdEncoding.DecodeString(s)
+	}
 	return v, nil
 }
 
@@ -65,6 +124,80 @@ func (f Fs) Delete(key string) error {
 	return nil
 }
 
+// fsLock serializes the conditional updates of files by the process
+var fsLock sync.Mutex
+
+// Incr adds delta to the counter at key in File System storage. Updates
+// are atomic within the process only
+func (f Fs) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	fsLock.Lock()
+	defer fsLock.Unlock()
+
+	path := f.path(key)
+	b, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	if err != nil || fileExpired(b, time.Now()) {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return 0, err
+		}
+		return delta, writeFile(path, []byte(strconv.FormatInt(delta, 10)), expiresIn(ttl))
+	}
+
+	var expires int64
+	if bytes.HasPrefix(b, fsMagic) && len(b) >= fsHeaderLen {
+		expires = int64(binary.BigEndian.Uint64(b[len(fsMagic):]))
+		b = b[fsHeaderLen:]
+	}
+	n, ok := counter(b)
+	if !ok {
+		return 0, ErrNotCounter
+	}
+	n += delta
+	return n, writeFile(path, []byte(strconv.FormatInt(n, 10)), expires)
+}
+
+// Add writes the given content for the given key in File System storage
+// unless the key exists. It is atomic within the process only
+func (f Fs) Add(ctx context.Context, key string, v interface{}, ttl time.Duration) error {
+	fsLock.Lock()
+	defer fsLock.Unlock()
+
+	if f.exists(key) {
+		return ErrKeyExists
+	}
+	return f.Write(key, v, ttl)
+}
+
+// Replace writes the given content for the given key in File System
+// storage if the key exists. It is atomic within the process only
+func (f Fs) Replace(ctx context.Context, key string, v interface{}, ttl time.Duration) error {
+	fsLock.Lock()
+	defer fsLock.Unlock()
+
+	if !f.exists(key) {
+		return ErrKeyNotExist
+	}
+	return f.Write(key, v, ttl)
+}
+
+// exists reports whether there is an unexpired file for the key
+func (f Fs) exists(key string) bool {
+	file, err := os.Open(f.path(key))
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	header := make([]byte, fsHeaderLen)
+	n, _ := io.ReadFull(file, header)
+	return !fileExpired(header[:n], time.Now())
+}
+
+// StoresBytes marks File System as a byte storage
+func (f Fs) StoresBytes() {}
+
 // Flush flushes File System storage
 func (f Fs) Flush() error {
 	d, err := os.Open(f.dir)
@@ -84,6 +217,42 @@ func (f Fs) Flush() error {
 	return os.Remove(f.dir)
 }
 
+// GC removes expired files from File System storage
+func (f Fs) GC() error {
+	now := time.Now()
+	err := filepath.Walk(f.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		header := make([]byte, fsHeaderLen)
+		n, _ := io.ReadFull(file, header)
+		file.Close()
+
+		if fileExpired(header[:n], now) {
+			return os.Remove(path)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// fileExpired reports whether the file starting with b has expired by now
+func fileExpired(b []byte, now time.Time) bool {
+	if !bytes.HasPrefix(b, fsMagic) || len(b) < fsHeaderLen {
+		return false
+	}
+	expires := int64(binary.BigEndian.Uint64(b[len(fsMagic):]))
+	return expires != 0 && expires <= now.UnixNano()
+}
+
 func (f Fs) path(key string) string {
 	h := sha1.New()
 	io.WriteString(h, key)
@@ -1,7 +1,11 @@
 package cache
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"gotest.tools/assert"
 )
@@ -23,6 +27,24 @@ func TestFilesystem_Write(t *testing.T) {
 	assert.NilError(t, fs.Flush())
 }
 
+func TestFilesystem_WriteBytes(t *testing.T) {
+	fs := Filesystem("./cache")
+	defer fs.Flush()
+
+	// raw bytes which happen to be a JSON object aren't an envelope
+	assert.NilError(t, fs.Write("key1", []byte(`{"a":1}`), 0))
+	v, err := fs.Read("key1")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []byte(`{"a":1}`), v)
+
+	b, err := encodeItem(encoding{codec: JSON}, item{Key: "key2", Val: 1})
+	assert.NilError(t, err)
+	assert.NilError(t, fs.Write("key2", b, 0))
+	v, err = fs.Read("key2")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, b, v)
+}
+
 func TestFilesystem_Delete(t *testing.T) {
 	fs := Filesystem("./cache")
 	fs.Write("key", "val", 0)
@@ -42,3 +64,51 @@ func TestFilesystem_WriteErrorCreatingDir(t *testing.T) {
 	fs := Filesystem("/root/cache")
 	assert.Assert(t, fs.Write("key1", "val1", 0) != nil)
 }
+
+func TestFilesystem_Expire(t *testing.T) {
+	fs := Filesystem("./cache")
+	defer fs.Flush()
+
+	assert.NilError(t, fs.Write("key1", "val1", 50*time.Millisecond))
+	assert.NilError(t, fs.Write("key2", []byte("val2"), time.Minute))
+
+	v, err := fs.Read("key1")
+	assert.NilError(t, err)
+	assert.Equal(t, v, "val1")
+
+	time.Sleep(60 * time.Millisecond)
+
+	_, err = fs.Read("key1")
+	assert.ErrorType(t, err, ErrKeyNotExist)
+	_, err = os.Stat(fs.path("key1"))
+	assert.Assert(t, os.IsNotExist(err))
+
+	v, err = fs.Read("key2")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, v, []byte("val2"))
+}
+
+func TestFilesystem_GC(t *testing.T) {
+	fs := Filesystem("./cache")
+	defer fs.Flush()
+
+	fs.Write("key1", "val1", time.Millisecond)
+	fs.Write("key2", "val2", time.Minute)
+	fs.Write("key3", "val3", 0)
+
+	// files written before expiry was stored never expire
+	assert.NilError(t, os.MkdirAll(filepath.Dir(fs.path("key4")), 0700))
+	assert.NilError(t, ioutil.WriteFile(fs.path("key4"), []byte(`{"key4":"val4"}`), 0600))
+
+	time.Sleep(5 * time.Millisecond)
+	assert.NilError(t, fs.GC())
+
+	_, err := os.Stat(fs.path("key1"))
+	assert.Assert(t, os.IsNotExist(err))
+	for _, key := range []string{"key2", "key3", "key4"} {
+		_, err := fs.Read(key)
+		assert.NilError(t, err)
+	}
+
+	assert.NilError(t, Filesystem("./missing").GC())
+}
@@ -1,7 +1,11 @@
 package cache
 
 import (
+	"container/heap"
+	"context"
+	"encoding/json"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,14 +16,99 @@ type InMem struct {
 	expire map[string]time.Time
 	done   chan struct{}
 	sync.RWMutex
+
+	maxEntries int
+	maxCost    int64
+	costFn     func(key string, v interface{}) int64
+	costs      map[string]int64
+	cost       int64
+	eviction   EvictionPolicy
+	policy     policy
+	evictions  uint64
+
+	// evicted and expired are the entries evicted and removed once expired
+	// while locked, which are reported to onEvict and onExpire once
+	// unlocked
+	evicted  []entry
+	expired  []entry
+	onEvict  []*watcher
+	onExpire []*watcher
+	watched  atomic.Bool
+
+	janitor  time.Duration
+	expiries expiryHeap
+	close    sync.Once
+
+	// versions are the versions of the entries for compare-and-swap,
+	// taken from a counter which is never reset
+	versions map[string]uint64
+	version  uint64
+}
+
+// InMemOption is the type of constructor options for InMemory(...)
+type InMemOption func(i *InMem)
+
+// WithMaxEntries bounds the number of entries kept in memory. Entries
+// are evicted according to the eviction policy once the bound is hit
+func WithMaxEntries(n int) InMemOption {
+	return func(i *InMem) {
+		i.maxEntries = n
+	}
+}
+
+// WithMaxCost bounds the total cost of the entries kept in memory.
+// Entries are evicted according to the eviction policy once the bound
+// is hit. The cost of an entry is its approximate size in bytes unless
+// configured with WithCost
+func WithMaxCost(n int64) InMemOption {
+	return func(i *InMem) {
+		i.maxCost = n
+	}
+}
+
+// WithCost configures the function computing the cost of an entry for
+// WithMaxCost
+func WithCost(cost func(key string, v interface{}) int64) InMemOption {
+	return func(i *InMem) {
+		i.costFn = cost
+	}
+}
+
+// WithEvictionPolicy configures the policy used to evict entries from
+// a bounded in memory storage. It's LRU by default
+func WithEvictionPolicy(p EvictionPolicy) InMemOption {
+	return func(i *InMem) {
+		i.eviction = p
+	}
+}
+
+// WithJanitor starts a goroutine removing expired entries every interval
+// rather than only when they are read. It is stopped by Close
+func WithJanitor(interval time.Duration) InMemOption {
+	return func(i *InMem) {
+		i.janitor = interval
+	}
 }
 
 // InMemory creates a new in memory storage which can be passed to
 // cache.New(WithStorage(...))
-func InMemory() *InMem {
+func InMemory(options ...InMemOption) *InMem {
 	inMemory := &InMem{
-		data:   make(map[string]interface{}),
-		expire: make(map[string]time.Time),
+		data:     make(map[string]interface{}),
+		expire:   make(map[string]time.Time),
+		versions: make(map[string]uint64),
+		costFn:   size,
+	}
+	for _, option := range options {
+		option(inMemory)
+	}
+	if inMemory.bounded() {
+		inMemory.costs = make(map[string]int64)
+		inMemory.policy = newPolicy(inMemory.eviction, inMemory.maxEntries)
+	}
+	if inMemory.janitor > 0 {
+		inMemory.done = make(chan struct{})
+		go inMemory.sweepEvery(inMemory.janitor)
 	}
 	return inMemory
 }
@@ -27,33 +116,50 @@ func InMemory() *InMem {
 // Write writes the given content for the given key in
 // memory storage
 func (i *InMem) Write(key string, v interface{}, d time.Duration) error {
+	defer i.notify()
 	i.Lock()
 	defer i.Unlock()
 
-	i.data[key] = v
-	if d != 0 {
-		i.expire[key] = time.Now().Add(d)
-	}
+	i.set(key, v, d)
+	i.evict()
 	return nil
 }
 
 // Read reads coontent for the given key from in memory storage
 func (i *InMem) Read(key string) (interface{}, error) {
-	i.RLock()
-	defer i.RUnlock()
+	if !i.bounded() {
+		// no policy records accesses, so only deleting expired
+		// entries needs the write lock
+		i.RLock()
+		v, ok := i.data[key]
+		expire, expires := i.expire[key]
+		i.RUnlock()
 
-	v, ok := i.data[key]
-	if !ok {
-		return nil, ErrKeyNotExist
+		if !ok {
+			return nil, ErrKeyNotExist
+		}
+		if !expires || !expire.Before(time.Now()) {
+			return v, nil
+		}
 	}
-	expire, ok := i.expire[key]
-	if ok && expire.Before(time.Now()) {
-		i.del(key)
+
+	defer i.notify()
+	i.Lock()
+	defer i.Unlock()
+
+	v, ok := i.get(key)
+	if !ok {
 		return nil, ErrKeyNotExist
 	}
 	return v, nil
 }
 
+// bounded reports whether the storage is bounded and thus has an eviction
+// policy recording the accesses
+func (i *InMem) bounded() bool {
+	return i.maxEntries > 0 || i.maxCost > 0
+}
+
 // Delete deletes content of the given key from in memory storage
 func (i *InMem) Delete(key string) error {
 	i.Lock()
@@ -62,16 +168,509 @@ func (i *InMem) Delete(key string) error {
 	err := i.del(key)
 	return err
 }
+
+func (i *InMem) set(key string, v interface{}, d time.Duration) {
+	var cost int64
+	if i.policy != nil && i.maxCost > 0 {
+		cost = i.costFn(key, v)
+	}
+	if i.policy != nil {
+		i.reserve(key, cost)
+	}
+
+	_, exists := i.data[key]
+	i.data[key] = v
+	i.version++
+	i.versions[key] = i.version
+	if d != 0 {
+		expire := time.Now().Add(d)
+		i.expire[key] = expire
+		if i.janitor > 0 {
+			i.expireAt(key, expire)
+		}
+	} else {
+		delete(i.expire, key)
+	}
+
+	if i.policy == nil {
+		return
+	}
+	if i.maxCost > 0 {
+		i.cost += cost - i.costs[key]
+		i.costs[key] = cost
+	}
+	if exists {
+		i.policy.access(key)
+	} else {
+		i.policy.add(key)
+	}
+}
+
+func (i *InMem) access(key string) {
+	if i.policy != nil {
+		i.policy.access(key)
+	}
+}
+
+// reserve evicts entries until key can be written with the given cost
+// without exceeding the bounds of the storage
+func (i *InMem) reserve(key string, cost int64) {
+	for {
+		entries, total := len(i.data)+1, i.cost+cost
+		if _, ok := i.data[key]; ok {
+			entries, total = entries-1, total-i.costs[key]
+		}
+		if !i.exceeds(entries, total) {
+			return
+		}
+
+		victim, ok := i.policy.victim()
+		if !ok {
+			return
+		}
+		i.evictKey(victim)
+	}
+}
+
+// evict evicts entries until the storage is within its bounds, which
+// is only needed once a single entry exceeds them
+func (i *InMem) evict() {
+	if i.policy == nil {
+		return
+	}
+	for i.exceeds(len(i.data), i.cost) {
+		key, ok := i.policy.victim()
+		if !ok {
+			return
+		}
+		i.evictKey(key)
+	}
+}
+
+// evictKey evicts the entry of key, to be reported by notify
+func (i *InMem) evictKey(key string) {
+	if len(i.onEvict) > 0 {
+		i.evicted = append(i.evicted, entry{key: key, v: i.data[key]})
+	}
+	i.del(key)
+	i.evictions++
+}
+
+// expireKey removes the expired entry of key, to be reported by notify
+func (i *InMem) expireKey(key string) {
+	if len(i.onExpire) > 0 {
+		i.expired = append(i.expired, entry{key: key, v: i.data[key]})
+	}
+	i.del(key)
+}
+
+// OnEvict registers fn to be called with the key and value of every
+// entry evicted to keep the storage within its bounds. fn is called once
+// the storage is unlocked, by the goroutine whose write has caused the
+// eviction. The returned function unregisters fn
+func (i *InMem) OnEvict(fn func(key string, v interface{})) (remove func()) {
+	return i.watch(&i.onEvict, fn)
+}
+
+// OnExpire registers fn to be called with the key and value of every
+// entry removed once expired, when it's read or by the janitor. fn is
+// called once the storage is unlocked. The returned function unregisters
+// fn
+func (i *InMem) OnExpire(fn func(key string, v interface{})) (remove func()) {
+	return i.watch(&i.onExpire, fn)
+}
+
+func (i *InMem) watch(watchers *[]*watcher, fn func(key string, v interface{})) func() {
+	i.Lock()
+	defer i.Unlock()
+
+	w := &watcher{fn: fn}
+	*watchers = append(*watchers, w)
+	i.watched.Store(true)
+	return func() { i.unwatch(w) }
+}
+
+// unwatch unregisters w, so that entries aren't recorded for notify
+// anymore once nothing watches the storage
+func (i *InMem) unwatch(w *watcher) {
+	i.Lock()
+	defer i.Unlock()
+
+	for _, watchers := range []*[]*watcher{&i.onEvict, &i.onExpire} {
+		kept := (*watchers)[:0:0]
+		for _, x := range *watchers {
+			if x != w {
+				kept = append(kept, x)
+			}
+		}
+		*watchers = kept
+	}
+	i.watched.Store(len(i.onEvict) > 0 || len(i.onExpire) > 0)
+}
+
+// notify reports the evicted and expired entries to the OnEvict and
+// OnExpire functions. It must be called unlocked
+func (i *InMem) notify() {
+	if !i.watched.Load() {
+		return
+	}
+
+	i.Lock()
+	evicted, onEvict := i.evicted, i.onEvict
+	expired, onExpire := i.expired, i.onExpire
+	i.evicted, i.expired = nil, nil
+	i.Unlock()
+
+	for _, e := range evicted {
+		for _, w := range onEvict {
+			w.fn(e.key, e.v)
+		}
+	}
+	for _, e := range expired {
+		for _, w := range onExpire {
+			w.fn(e.key, e.v)
+		}
+	}
+}
+
+type entry struct {
+	key string
+	v   interface{}
+}
+
+// watcher is a function registered with OnEvict or OnExpire, kept behind
+// a pointer to be told apart when removed
+type watcher struct {
+	fn func(key string, v interface{})
+}
+
+// Evictions returns the number of entries evicted to keep the storage
+// within its bounds
+func (i *InMem) Evictions() uint64 {
+	i.RLock()
+	defer i.RUnlock()
+
+	return i.evictions
+}
+
+func (i *InMem) exceeds(entries int, cost int64) bool {
+	return (i.maxEntries > 0 && entries > i.maxEntries) || (i.maxCost > 0 && cost > i.maxCost)
+}
+
 func (i *InMem) del(key string) error {
+	if _, ok := i.data[key]; ok && i.policy != nil {
+		i.policy.remove(key)
+		i.cost -= i.costs[key]
+		delete(i.costs, key)
+	}
 	delete(i.data, key)
 	delete(i.expire, key)
+	delete(i.versions, key)
 	return nil
 }
 
+// size approximates the size of an entry in bytes
+func size(key string, v interface{}) int64 {
+	switch x := v.(type) {
+	case []byte:
+		return int64(len(key) + len(x))
+	case string:
+		return int64(len(key) + len(x))
+	case item:
+		return size(key, x.Val)
+	}
+	b, _ := json.Marshal(v)
+	return int64(len(key) + len(b))
+}
+
+// ReadMulti reads content for the given keys from in memory storage
+func (i *InMem) ReadMulti(ctx context.Context, keys ...string) (map[string]interface{}, error) {
+	if !i.bounded() {
+		if values, ok := i.readMulti(keys); ok {
+			return values, nil
+		}
+	}
+
+	defer i.notify()
+	i.Lock()
+	defer i.Unlock()
+
+	var (
+		now    = time.Now()
+		values = make(map[string]interface{}, len(keys))
+	)
+	for _, key := range keys {
+		i.access(key)
+		v, ok := i.data[key]
+		if !ok {
+			continue
+		}
+		if expire, ok := i.expire[key]; ok && expire.Before(now) {
+			i.expireKey(key)
+			continue
+		}
+		values[key] = v
+	}
+	return values, nil
+}
+
+// readMulti reads the given keys under the read lock unless one of them
+// has expired and has to be deleted
+func (i *InMem) readMulti(keys []string) (map[string]interface{}, bool) {
+	i.RLock()
+	defer i.RUnlock()
+
+	var (
+		now    = time.Now()
+		values = make(map[string]interface{}, len(keys))
+	)
+	for _, key := range keys {
+		v, ok := i.data[key]
+		if !ok {
+			continue
+		}
+		if expire, ok := i.expire[key]; ok && expire.Before(now) {
+			return nil, false
+		}
+		values[key] = v
+	}
+	return values, true
+}
+
+// WriteMulti writes all the given key-value pairs in
+// memory storage
+func (i *InMem) WriteMulti(ctx context.Context, values map[string]interface{}, d time.Duration) error {
+	defer i.notify()
+	i.Lock()
+	defer i.Unlock()
+
+	for key, v := range values {
+		i.set(key, v, d)
+	}
+	i.evict()
+	return nil
+}
+
+// DeleteMulti deletes content of the given keys from in memory storage
+func (i *InMem) DeleteMulti(ctx context.Context, keys ...string) error {
+	i.Lock()
+	defer i.Unlock()
+
+	for _, key := range keys {
+		i.del(key)
+	}
+	return nil
+}
+
+// ReadVersion reads content for the given key from in memory storage
+// along with its version
+func (i *InMem) ReadVersion(ctx context.Context, key string) (interface{}, uint64, error) {
+	defer i.notify()
+	i.Lock()
+	defer i.Unlock()
+
+	v, ok := i.get(key)
+	if !ok {
+		return nil, 0, ErrKeyNotExist
+	}
+	return v, i.versions[key], nil
+}
+
+// CompareAndWrite writes the given content for the given key in memory
+// storage if the content still has the given version
+func (i *InMem) CompareAndWrite(ctx context.Context, key string, version uint64, v interface{}, d time.Duration) error {
+	defer i.notify()
+	i.Lock()
+	defer i.Unlock()
+
+	if _, ok := i.get(key); !ok {
+		return ErrKeyNotExist
+	}
+	if i.versions[key] != version {
+		return ErrVersionMismatch
+	}
+	i.set(key, v, d)
+	i.evict()
+	return nil
+}
+
+// CompareAndDelete deletes content of the given key from in memory
+// storage if the content still has the given version
+func (i *InMem) CompareAndDelete(ctx context.Context, key string, version uint64) error {
+	defer i.notify()
+	i.Lock()
+	defer i.Unlock()
+
+	if _, ok := i.get(key); !ok {
+		return ErrKeyNotExist
+	}
+	if i.versions[key] != version {
+		return ErrVersionMismatch
+	}
+	return i.del(key)
+}
+
+// Incr adds delta to the counter at key in memory storage
+func (i *InMem) Incr(ctx context.Context, key string, delta int64, d time.Duration) (int64, error) {
+	defer i.notify()
+	i.Lock()
+	defer i.Unlock()
+
+	var n int64
+	if v, ok := i.get(key); ok {
+		if n, ok = counter(v); !ok {
+			return 0, ErrNotCounter
+		}
+		d = 0
+		if expire, ok := i.expire[key]; ok {
+			d = time.Until(expire)
+		}
+	}
+
+	n += delta
+	i.set(key, n, d)
+	i.evict()
+	return n, nil
+}
+
+// Add writes the given content for the given key in memory storage
+// unless the key exists
+func (i *InMem) Add(ctx context.Context, key string, v interface{}, d time.Duration) error {
+	defer i.notify()
+	i.Lock()
+	defer i.Unlock()
+
+	if _, ok := i.get(key); ok {
+		return ErrKeyExists
+	}
+	i.set(key, v, d)
+	i.evict()
+	return nil
+}
+
+// Replace writes the given content for the given key in memory storage
+// if the key exists
+func (i *InMem) Replace(ctx context.Context, key string, v interface{}, d time.Duration) error {
+	defer i.notify()
+	i.Lock()
+	defer i.Unlock()
+
+	if _, ok := i.get(key); !ok {
+		return ErrKeyNotExist
+	}
+	i.set(key, v, d)
+	i.evict()
+	return nil
+}
+
+// get returns the content of key unless it is missing or expired, in
+// which case it is deleted
+func (i *InMem) get(key string) (interface{}, bool) {
+	i.access(key)
+	v, ok := i.data[key]
+	if !ok {
+		return nil, false
+	}
+	if expire, ok := i.expire[key]; ok && expire.Before(time.Now()) {
+		i.expireKey(key)
+		return nil, false
+	}
+	return v, true
+}
+
 // Flush flushes in momory storage
 func (i *InMem) Flush() error {
+	i.Lock()
+	defer i.Unlock()
+
 	i.data = make(map[string]interface{})
 	i.expire = make(map[string]time.Time)
+	i.versions = make(map[string]uint64)
+	i.expiries = nil
+	if i.policy != nil {
+		i.costs = make(map[string]int64)
+		i.cost = 0
+		i.policy = newPolicy(i.eviction, i.maxEntries)
+	}
 
 	return nil
 }
+
+// Close stops the janitor if there is one
+func (i *InMem) Close() error {
+	i.close.Do(func() {
+		if i.done != nil {
+			close(i.done)
+		}
+	})
+	return nil
+}
+
+func (i *InMem) sweepEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-i.done:
+			return
+		case now := <-ticker.C:
+			i.sweep(now)
+		}
+	}
+}
+
+// sweep removes the entries expired by now
+func (i *InMem) sweep(now time.Time) {
+	defer i.notify()
+	i.Lock()
+	defer i.Unlock()
+
+	for len(i.expiries) > 0 && i.expiries[0].at.Before(now) {
+		e := heap.Pop(&i.expiries).(expiry)
+
+		// the entry may have been deleted or rewritten since
+		if expire, ok := i.expire[e.key]; ok && expire.Equal(e.at) {
+			i.expireKey(e.key)
+		}
+	}
+}
+
+// expireAt schedules the removal of key. Rewritten entries leave stale
+// expiries behind, so the heap is rebuilt when they outnumber live ones
+func (i *InMem) expireAt(key string, at time.Time) {
+	heap.Push(&i.expiries, expiry{key: key, at: at})
+	if len(i.expiries) <= 2*len(i.expire)+64 {
+		return
+	}
+
+	i.expiries = i.expiries[:0]
+	for key, at := range i.expire {
+		i.expiries = append(i.expiries, expiry{key: key, at: at})
+	}
+	heap.Init(&i.expiries)
+}
+
+type expiry struct {
+	key string
+	at  time.Time
+}
+
+// expiryHeap orders expiries by time so that the janitor only visits
+// expired entries
+type expiryHeap []expiry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *expiryHeap) Push(x interface{}) {
+	*h = append(*h, x.(expiry))
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
@@ -1,6 +1,11 @@
 package cache
 
 import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -126,3 +131,235 @@ func TestInMemory_Del(t *testing.T) {
 	assert.Equal(t, 3, len(inMemory.data))
 	assert.Equal(t, 2, len(inMemory.expire))
 }
+
+func TestInMemory_MaxEntries(t *testing.T) {
+	inMemory := InMemory(WithMaxEntries(3))
+
+	inMemory.Write("key1", 1, 0)
+	inMemory.Write("key2", 2, 0)
+	inMemory.Write("key3", 3, 0)
+	inMemory.Read("key1")
+	inMemory.Write("key4", 4, time.Minute)
+
+	assert.Equal(t, 3, len(inMemory.data))
+	_, err := inMemory.Read("key2")
+	assert.Error(t, err, ErrKeyNotExist.Error())
+
+	inMemory.Write("key5", 5, 0)
+	assert.Equal(t, 3, len(inMemory.data))
+	_, err = inMemory.Read("key3")
+	assert.Error(t, err, ErrKeyNotExist.Error())
+
+	for _, key := range []string{"key1", "key4", "key5"} {
+		_, err = inMemory.Read(key)
+		assert.NilError(t, err)
+	}
+}
+
+func TestInMemory_OnEvict(t *testing.T) {
+	inMemory := InMemory(WithMaxEntries(1))
+
+	var evicted []string
+	inMemory.OnEvict(func(key string, v interface{}) {
+		// the storage is unlocked already
+		_, err := inMemory.Read(key)
+		assert.Error(t, err, ErrKeyNotExist.Error())
+		evicted = append(evicted, fmt.Sprintf("%s=%v", key, v))
+	})
+
+	inMemory.Write("key1", 1, 0)
+	inMemory.Write("key2", 2, 0)
+	inMemory.WriteMulti(context.Background(), map[string]interface{}{"key3": 3}, 0)
+	assert.DeepEqual(t, []string{"key1=1", "key2=2"}, evicted)
+}
+
+func TestInMemory_OnExpire(t *testing.T) {
+	inMemory := InMemory()
+
+	var expired []string
+	remove := inMemory.OnExpire(func(key string, v interface{}) {
+		expired = append(expired, fmt.Sprintf("%s=%v", key, v))
+	})
+
+	inMemory.Write("key1", 1, 10*time.Millisecond)
+	inMemory.Write("key2", 2, 10*time.Millisecond)
+	inMemory.Write("key3", 3, time.Minute)
+	time.Sleep(20 * time.Millisecond)
+
+	_, err := inMemory.Read("key1")
+	assert.Error(t, err, ErrKeyNotExist.Error())
+	inMemory.ReadMulti(context.Background(), "key2", "key3")
+	assert.DeepEqual(t, []string{"key1=1", "key2=2"}, expired)
+
+	remove()
+	assert.Assert(t, !inMemory.watched.Load())
+}
+
+func TestInMemory_MaxCost(t *testing.T) {
+	inMemory := InMemory(WithMaxCost(20))
+
+	inMemory.Write("key1", "012345", 0)
+	inMemory.Write("key2", "012345", 0)
+	assert.Equal(t, int64(20), inMemory.cost)
+
+	inMemory.Write("key3", "01", 0)
+	assert.Equal(t, 2, len(inMemory.data))
+	assert.Equal(t, int64(16), inMemory.cost)
+
+	inMemory.Write("key3", "0123456789", 0)
+	assert.Equal(t, 1, len(inMemory.data))
+	assert.Equal(t, int64(14), inMemory.cost)
+
+	inMemory.Delete("key3")
+	assert.Equal(t, int64(0), inMemory.cost)
+
+	inMemory = InMemory(WithMaxCost(3), WithCost(func(string, interface{}) int64 { return 1 }))
+	for _, key := range []string{"key1", "key2", "key3", "key4"} {
+		inMemory.Write(key, key, 0)
+	}
+	assert.Equal(t, 3, len(inMemory.data))
+}
+
+func TestInMemory_LFU(t *testing.T) {
+	inMemory := InMemory(WithMaxEntries(2), WithEvictionPolicy(LFU))
+
+	inMemory.Write("key1", 1, 0)
+	inMemory.Read("key1")
+	inMemory.Read("key1")
+	inMemory.Write("key2", 2, 0)
+	inMemory.Read("key2")
+	inMemory.Write("key3", 3, 0)
+
+	_, err := inMemory.Read("key2")
+	assert.Error(t, err, ErrKeyNotExist.Error())
+	_, err = inMemory.Read("key1")
+	assert.NilError(t, err)
+}
+
+func TestInMemory_HitRate(t *testing.T) {
+	const (
+		capacity = 500
+		keys     = 50000
+		requests = 200000
+	)
+
+	hitRate := func(p EvictionPolicy) float64 {
+		var (
+			inMemory = InMemory(WithMaxEntries(capacity), WithEvictionPolicy(p))
+			zipf     = rand.NewZipf(rand.New(rand.NewSource(1)), 1.01, 1, keys-1)
+			hits     int
+		)
+		for n := 0; n < requests; n++ {
+			key := strconv.FormatUint(zipf.Uint64(), 10)
+			if _, err := inMemory.Read(key); err == nil {
+				hits++
+				continue
+			}
+			inMemory.Write(key, key, 0)
+		}
+		assert.Assert(t, len(inMemory.data) <= capacity)
+		return float64(hits) / requests
+	}
+
+	var (
+		lru     = hitRate(LRU)
+		lfu     = hitRate(LFU)
+		tinyLFU = hitRate(TinyLFU)
+	)
+	t.Logf("hit rates: LRU %.3f, LFU %.3f, TinyLFU %.3f", lru, lfu, tinyLFU)
+
+	assert.Assert(t, lru > 0.3, lru)
+	assert.Assert(t, lfu > lru, lfu)
+	assert.Assert(t, tinyLFU > lru, tinyLFU)
+}
+
+func TestInMemory_Janitor(t *testing.T) {
+	inMemory := InMemory(WithJanitor(10 * time.Millisecond))
+	defer inMemory.Close()
+
+	inMemory.Write("key1", 1, 0)
+	inMemory.Write("key2", 2, 20*time.Millisecond)
+	inMemory.Write("key3", 3, time.Minute)
+	inMemory.Write("key4", 4, 20*time.Millisecond)
+	inMemory.Write("key4", 4, 0)
+
+	time.Sleep(100 * time.Millisecond)
+
+	inMemory.RLock()
+	assert.Equal(t, 3, len(inMemory.data))
+	assert.Equal(t, 1, len(inMemory.expire))
+	_, ok := inMemory.data["key2"]
+	inMemory.RUnlock()
+	assert.Assert(t, !ok)
+}
+
+func TestInMemory_JanitorRewrites(t *testing.T) {
+	inMemory := InMemory(WithJanitor(time.Hour))
+	defer inMemory.Close()
+
+	for n := 0; n < 1000; n++ {
+		inMemory.Write("key1", n, time.Minute)
+	}
+	assert.Assert(t, len(inMemory.expiries) <= 66)
+
+	inMemory.sweep(time.Now().Add(2 * time.Minute))
+	assert.Equal(t, 0, len(inMemory.data))
+	assert.Equal(t, 0, len(inMemory.expiries))
+}
+
+func TestInMemory_Close(t *testing.T) {
+	inMemory := InMemory(WithJanitor(time.Millisecond))
+	c := New(WithStorage(InMemory()), WithMediumPriorityStorage(inMemory))
+
+	assert.NilError(t, c.Close())
+	assert.NilError(t, inMemory.Close())
+
+	_, open := <-inMemory.done
+	assert.Assert(t, !open)
+}
+
+func TestInMemory_ReadConcurrently(t *testing.T) {
+	inMemory := InMemory()
+	inMemory.Write("key1", 1, 0)
+	inMemory.Write("key2", 2, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < 100; k++ {
+				v, err := inMemory.Read("key1")
+				if err != nil || v != 1 {
+					t.Errorf("unexpected %v, %v", v, err)
+					return
+				}
+				inMemory.Read("key2")
+				inMemory.ReadMulti(context.Background(), "key1", "key2")
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, len(inMemory.data))
+}
+
+func TestInMemory_IncrBounded(t *testing.T) {
+	ctx := context.Background()
+	inMemory := InMemory(WithMaxCost(10), WithCost(func(key string, v interface{}) int64 {
+		if n, ok := v.(int64); ok {
+			return n
+		}
+		return 1
+	}))
+
+	inMemory.Write("key1", "abc", 0)
+	_, err := inMemory.Incr(ctx, "counter", 5, 0)
+	assert.NilError(t, err)
+	_, err = inMemory.Incr(ctx, "counter", 20, 0)
+	assert.NilError(t, err)
+
+	assert.Assert(t, inMemory.cost <= 10)
+	assert.Equal(t, 0, len(inMemory.data))
+}
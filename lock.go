@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	mrand "math/rand"
+	"time"
+)
+
+var (
+	// ErrLockHeld indicates that the lock is held by someone else
+	ErrLockHeld = errors.New("lock is held")
+
+	// ErrLockNotHeld indicates that the lock isn't held with the given
+	// token anymore, e.g. because its lease has expired
+	ErrLockNotHeld = errors.New("lock is not held")
+)
+
+// Locker holds named locks in a storage shared by several processes, e.g.
+// Redis. A lock is held with a lease expiring after a ttl unless extended,
+// so that a crashed holder doesn't keep it forever
+type Locker struct {
+	s          LockStorage
+	ns         string
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// LockerOption is the type of constructor options for NewLocker(...)
+type LockerOption func(l *Locker)
+
+// WithLockNamespace configures the namespace the keys of the locks are
+// prefixed with. It's "go:cache:lock" by default
+func WithLockNamespace(ns string) LockerOption {
+	return func(l *Locker) {
+		l.ns = ns
+	}
+}
+
+// minLockBackoff is the least Lock waits between attempts, so that it
+// doesn't spin on the storage
+const minLockBackoff = time.Millisecond
+
+// WithLockBackoff configures how long Lock waits between attempts to
+// acquire a lock. The wait starts at min and doubles up to max, with
+// jitter. It's 10ms to 1s by default, min is 1ms at least and max is min
+// at least
+func WithLockBackoff(min, max time.Duration) LockerOption {
+	return func(l *Locker) {
+		if min < minLockBackoff {
+			min = minLockBackoff
+		}
+		if max < min {
+			max = min
+		}
+		l.minBackoff, l.maxBackoff = min, max
+	}
+}
+
+// NewLocker creates a new Locker holding locks in the given storage, which
+// has to implement LockStorage as in memory, Redis and memcached storage
+// do. ErrNotSupported is returned otherwise
+func NewLocker(s Storage, options ...LockerOption) (*Locker, error) {
+	ls, ok := s.(LockStorage)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	l := &Locker{
+		s:          ls,
+		ns:         "go:cache:lock",
+		minBackoff: 10 * time.Millisecond,
+		maxBackoff: time.Second,
+	}
+	for _, option := range options {
+		option(l)
+	}
+	return l, nil
+}
+
+func (l *Locker) key(name string) string {
+	return l.ns + ":" + name
+}
+
+// TryLock acquires the named lock for ttl and returns the token of the
+// lease, or fails with ErrLockHeld if the lock is held
+func (l *Locker) TryLock(ctx context.Context, name string, ttl time.Duration) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	if err := l.s.Add(ctx, l.key(name), []byte(token), ttl); err != nil {
+		if err == ErrKeyExists {
+			return "", ErrLockHeld
+		}
+		return "", err
+	}
+	return token, nil
+}
+
+// Lock acquires the named lock for ttl like TryLock, waiting for the lock
+// to be released until ctx is done
+func (l *Locker) Lock(ctx context.Context, name string, ttl time.Duration) (string, error) {
+	backoff := l.minBackoff
+	for {
+		token, err := l.TryLock(ctx, name, ttl)
+		if err != ErrLockHeld {
+			return token, err
+		}
+
+		// wait for half to all of the backoff so that waiters spread
+		wait := backoff/2 + time.Duration(mrand.Int63n(int64(backoff/2)+1))
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", ctx.Err()
+		case <-timer.C:
+		}
+
+		if backoff *= 2; backoff > l.maxBackoff {
+			backoff = l.maxBackoff
+		}
+	}
+}
+
+// Unlock releases the named lock if it is still held with the given
+// token and fails with ErrLockNotHeld otherwise
+func (l *Locker) Unlock(ctx context.Context, name, token string) error {
+	return l.held(ctx, name, token, func(key string, version uint64) error {
+		return l.s.CompareAndDelete(ctx, key, version)
+	})
+}
+
+// Extend renews the lease of the named lock for ttl from now if the lock
+// is still held with the given token and fails with ErrLockNotHeld
+// otherwise
+func (l *Locker) Extend(ctx context.Context, name, token string, ttl time.Duration) error {
+	return l.held(ctx, name, token, func(key string, version uint64) error {
+		return l.s.CompareAndWrite(ctx, key, version, []byte(token), ttl)
+	})
+}
+
+// held calls fn with the version of the named lock if it is held with
+// the given token
+func (l *Locker) held(ctx context.Context, name, token string, fn func(key string, version uint64) error) error {
+	key := l.key(name)
+	v, version, err := l.s.ReadVersion(ctx, key)
+	if err == ErrKeyNotExist {
+		return ErrLockNotHeld
+	}
+	if err != nil {
+		return err
+	}
+
+	var held string
+	switch x := v.(type) {
+	case []byte:
+		held = string(x)
+	case string:
+		held = x
+	}
+	if held != token {
+		return ErrLockNotHeld
+	}
+
+	switch err := fn(key, version); err {
+	case ErrKeyNotExist, ErrVersionMismatch:
+		return ErrLockNotHeld
+	default:
+		return err
+	}
+}
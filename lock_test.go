@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	redisClient "github.com/redis/go-redis/v9"
+	"gotest.tools/assert"
+)
+
+func TestLocker(t *testing.T) {
+	storages := map[string]func(t *testing.T) Storage{
+		"memcached": func(t *testing.T) Storage { return Memcached(memcached(t)) },
+		"redis": func(t *testing.T) Storage {
+			return Redis(&redisClient.Options{Addr: miniRedis(t).Addr()})
+		},
+		"in memory": func(t *testing.T) Storage { return InMemory() },
+	}
+
+	for name, storage := range storages {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			l, err := NewLocker(storage(t))
+			assert.NilError(t, err)
+
+			token, err := l.TryLock(ctx, "job", time.Minute)
+			assert.NilError(t, err)
+			_, err = l.TryLock(ctx, "job", time.Minute)
+			assert.Equal(t, ErrLockHeld, err)
+
+			// other locks are independent
+			_, err = l.TryLock(ctx, "other", time.Minute)
+			assert.NilError(t, err)
+
+			assert.Equal(t, ErrLockNotHeld, l.Unlock(ctx, "job", "someone else"))
+			assert.Equal(t, ErrLockNotHeld, l.Extend(ctx, "job", "someone else", time.Minute))
+			assert.NilError(t, l.Extend(ctx, "job", token, time.Minute))
+
+			assert.NilError(t, l.Unlock(ctx, "job", token))
+			assert.Equal(t, ErrLockNotHeld, l.Unlock(ctx, "job", token))
+
+			_, err = l.TryLock(ctx, "job", time.Minute)
+			assert.NilError(t, err)
+		})
+	}
+}
+
+func TestLocker_Lock(t *testing.T) {
+	ctx := context.Background()
+	l, err := NewLocker(InMemory(), WithLockBackoff(time.Millisecond, 5*time.Millisecond))
+	assert.NilError(t, err)
+
+	token, err := l.Lock(ctx, "job", time.Minute)
+	assert.NilError(t, err)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		l.Unlock(ctx, "job", token)
+	}()
+	_, err = l.Lock(ctx, "job", time.Minute)
+	assert.NilError(t, err)
+
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = l.Lock(ctx, "job", time.Minute)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestLocker_Backoff(t *testing.T) {
+	l, err := NewLocker(InMemory(), WithLockBackoff(0, -time.Second))
+	assert.NilError(t, err)
+	assert.Equal(t, minLockBackoff, l.minBackoff)
+	assert.Equal(t, minLockBackoff, l.maxBackoff)
+
+	l, err = NewLocker(InMemory(), WithLockBackoff(time.Second, time.Millisecond))
+	assert.NilError(t, err)
+	assert.Equal(t, time.Second, l.maxBackoff)
+}
+
+func TestLocker_Expired(t *testing.T) {
+	ctx := context.Background()
+	l, err := NewLocker(InMemory())
+	assert.NilError(t, err)
+
+	token, err := l.TryLock(ctx, "job", 10*time.Millisecond)
+	assert.NilError(t, err)
+	time.Sleep(20 * time.Millisecond)
+
+	// the lease has expired and the lock been taken over
+	_, err = l.TryLock(ctx, "job", time.Minute)
+	assert.NilError(t, err)
+	assert.Equal(t, ErrLockNotHeld, l.Extend(ctx, "job", token, time.Minute))
+	assert.Equal(t, ErrLockNotHeld, l.Unlock(ctx, "job", token))
+}
+
+func TestNewLocker(t *testing.T) {
+	_, err := NewLocker(Filesystem(t.TempDir()))
+	assert.Equal(t, ErrNotSupported, err)
+}
@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"log/slog"
+	"os"
+)
+
+// Logger receives the log events of a cache. Events are a message along
+// with alternating keys and values, e.g. "key", "user:1", the way log/slog
+// logs them, so a *slog.Logger is a Logger. The cachelogrus and cachezap
+// packages adapt logrus and zap loggers. Any struct implementing Logger
+// can be passed to cache.New(WithLogger(...))
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+var _ Logger = (*slog.Logger)(nil)
+
+// newLogger returns the default logger, which logs text to stderr at the
+// given level
+func newLogger(level *slog.LevelVar) Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+}
+
+// nopLogger discards all events
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+// loggerSetter is implemented by taggers logging events, which are given
+// the logger of the cache they're used with
+type loggerSetter interface {
+	withLogger(logger Logger) Tagger
+}
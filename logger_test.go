@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+type event struct {
+	level   string
+	msg     string
+	keyvals []interface{}
+}
+
+type logRecorder struct {
+	sync.Mutex
+	events []event
+}
+
+func (r *logRecorder) log(level, msg string, keyvals []interface{}) {
+	r.Lock()
+	defer r.Unlock()
+	r.events = append(r.events, event{level: level, msg: msg, keyvals: keyvals})
+}
+
+func (r *logRecorder) Debug(msg string, keyvals ...interface{}) { r.log("debug", msg, keyvals) }
+func (r *logRecorder) Info(msg string, keyvals ...interface{})  { r.log("info", msg, keyvals) }
+func (r *logRecorder) Warn(msg string, keyvals ...interface{})  { r.log("warn", msg, keyvals) }
+func (r *logRecorder) Error(msg string, keyvals ...interface{}) { r.log("error", msg, keyvals) }
+
+func (r *logRecorder) find(msg string) (event, bool) {
+	r.Lock()
+	defer r.Unlock()
+	for _, e := range r.events {
+		if e.msg == msg {
+			return e, true
+		}
+	}
+	return event{}, false
+}
+
+func TestCache_LogsMediumStorageErrors(t *testing.T) {
+	r := &logRecorder{}
+	c := New(
+		WithHighPriorityStorage(InMemory()),
+		WithMediumPriorityStorage(&mock{err: errors.New("some error")}),
+		WithLogger(r),
+	)
+
+	assert.NilError(t, c.Set("key1", 1, 0))
+	e, ok := r.find("Ignoring medium priority storage error")
+	assert.Assert(t, ok)
+	assert.Equal(t, "warn", e.level)
+	assert.Equal(t, "[storage mock error some error]", fmt.Sprint(e.keyvals))
+}
+
+func TestCache_RepairsTagIndex(t *testing.T) {
+	var (
+		r = &logRecorder{}
+		s = InMemory()
+		c = New(WithStorage(s), WithLogger(r))
+	)
+	assert.NilError(t, c.Set("key1", 1, 0, "tag1", "tag2"))
+	assert.NilError(t, c.Set("key2", 2, 0, "tag1"))
+
+	// the key is gone but still tagged
+	assert.NilError(t, s.Delete("go:cache:key1"))
+
+	var v []int
+	assert.NilError(t, c.ByTag("tag1", &v))
+	assert.DeepEqual(t, []int{2}, v)
+
+	e, ok := r.find("Removed missing key from tag index")
+	assert.Assert(t, ok)
+	assert.Equal(t, "info", e.level)
+	assert.Equal(t, "[key key1 tags [tag1 tag2] storage inmem]", fmt.Sprint(e.keyvals))
+
+	n, err := c.TagSize("tag1")
+	assert.NilError(t, err)
+	assert.Equal(t, 1, n)
+	n, err = c.TagSize("tag2")
+	assert.NilError(t, err)
+	assert.Equal(t, 0, n)
+}
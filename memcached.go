@@ -1,7 +1,8 @@
 package cache
 
 import (
-	"encoding/json"
+	"context"
+	"strconv"
 	"time"
 
 	"github.com/bradfitz/gomemcache/memcache"
@@ -29,15 +30,10 @@ func Memcached(servers ...string) Memcache {
 // Write writes the given content for the given key in
 // memcached storage
 func (m Memcache) Write(key string, v interface{}, ttl time.Duration) error {
-	b, err := json.Marshal(v)
+	item, err := m.item(key, v, ttl)
 	if err != nil {
 		return err
 	}
-
-	item := &memcache.Item{Key: key, Value: b}
-	if ttl > 0 {
-		item.Expiration = int32(time.Now().Add(ttl).Unix())
-	}
 	return m.client.Set(item)
 }
 
@@ -58,6 +54,197 @@ func (m Memcache) Delete(key string) error {
 	return m.client.Delete(key)
 }
 
+// ReadMulti reads content for the given keys from memcached storage
+// in a single round trip
+func (m Memcache) ReadMulti(ctx context.Context, keys ...string) (map[string]interface{}, error) {
+	items, err := m.client.GetMulti(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{}, len(items))
+	for key, item := range items {
+		values[key] = item.Value
+	}
+	return values, nil
+}
+
+// WriteMulti writes all the given key-value pairs in memcached storage.
+// memcached protocol has no multi set so values are written one by one
+func (m Memcache) WriteMulti(ctx context.Context, values map[string]interface{}, ttl time.Duration) error {
+	for key, v := range values {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := m.Write(key, v, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteMulti deletes content of the given keys from memcached storage
+func (m Memcache) DeleteMulti(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := m.client.Delete(key); err != nil && err != memcache.ErrCacheMiss {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add writes the given content for the given key in memcached storage
+// unless the key exists
+func (m Memcache) Add(ctx context.Context, key string, v interface{}, ttl time.Duration) error {
+	item, err := m.item(key, v, ttl)
+	if err != nil {
+		return err
+	}
+	if err := m.client.Add(item); err != memcache.ErrNotStored {
+		return err
+	}
+	return ErrKeyExists
+}
+
+// Replace writes the given content for the given key in memcached storage
+// if the key exists
+func (m Memcache) Replace(ctx context.Context, key string, v interface{}, ttl time.Duration) error {
+	item, err := m.item(key, v, ttl)
+	if err != nil {
+		return err
+	}
+	if err := m.client.Replace(item); err != memcache.ErrNotStored {
+		return err
+	}
+	return ErrKeyNotExist
+}
+
+// item returns the memcached item to write the given content with
+func (m Memcache) item(key string, v interface{}, ttl time.Duration) (*memcache.Item, error) {
+	b, err := marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	item := &memcache.Item{Key: key, Value: b}
+	if ttl > 0 {
+		item.Expiration = int32(time.Now().Add(ttl).Unix())
+	}
+	return item, nil
+}
+
+// ReadVersion reads content for the given key from memcached storage
+// along with its cas unique
+func (m Memcache) ReadVersion(ctx context.Context, key string) (interface{}, uint64, error) {
+	item, err := m.client.Get(key)
+	if err != nil {
+		if err == memcache.ErrCacheMiss {
+			return nil, 0, ErrKeyNotExist
+		}
+		return nil, 0, err
+	}
+	return item.Value, item.CasID, nil
+}
+
+// CompareAndWrite writes the given content for the given key in memcached
+// storage with a cas command if its cas unique is still version
+func (m Memcache) CompareAndWrite(ctx context.Context, key string, version uint64, v interface{}, ttl time.Duration) error {
+	b, err := marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return m.compareAndSwap(key, version, func(item *memcache.Item) {
+		item.Value, item.Expiration = b, 0
+		if ttl > 0 {
+			item.Expiration = int32(time.Now().Add(ttl).Unix())
+		}
+	})
+}
+
+// CompareAndDelete deletes content of the given key from memcached
+// storage if its cas unique is still version. The text protocol can't
+// delete with a cas unique, so the item is swapped for an expired one
+func (m Memcache) CompareAndDelete(ctx context.Context, key string, version uint64) error {
+	return m.compareAndSwap(key, version, func(item *memcache.Item) {
+		item.Value, item.Expiration = nil, -1
+	})
+}
+
+// compareAndSwap swaps the item for the given key for the item updated
+// by fn with a cas command if its cas unique is still version
+func (m Memcache) compareAndSwap(key string, version uint64, fn func(item *memcache.Item)) error {
+	item := &memcache.Item{Key: key, CasID: version}
+	fn(item)
+	switch err := m.client.CompareAndSwap(item); err {
+	case memcache.ErrCacheMiss:
+		return ErrKeyNotExist
+	case memcache.ErrCASConflict:
+		return ErrVersionMismatch
+	default:
+		return err
+	}
+}
+
+// Incr adds delta to the counter at key in memcached storage. memcached
+// counters are unsigned, so they are created at zero at least and don't
+// go below zero
+func (m Memcache) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		var (
+			n   uint64
+			err error
+		)
+		if delta >= 0 {
+			n, err = m.client.Increment(key, uint64(delta))
+		} else {
+			n, err = m.client.Decrement(key, uint64(-delta))
+		}
+		if err != memcache.ErrCacheMiss {
+			if err != nil && m.notCounter(key) {
+				return 0, ErrNotCounter
+			}
+			return int64(n), err
+		}
+
+		initial := delta
+		if initial < 0 {
+			initial = 0
+		}
+		item := &memcache.Item{Key: key, Value: []byte(strconv.FormatInt(initial, 10))}
+		if ttl > 0 {
+			item.Expiration = int32(time.Now().Add(ttl).Unix())
+		}
+		// the counter may have been created concurrently, in which case
+		// it is incremented again
+		if err := m.client.Add(item); err != memcache.ErrNotStored {
+			return initial, err
+		}
+	}
+}
+
+// notCounter reports whether the value at key is known not to be a
+// counter, which memcached fails to increment with a client error the
+// client has no sentinel for
+func (m Memcache) notCounter(key string) bool {
+	item, err := m.client.Get(key)
+	if err != nil {
+		return false
+	}
+	_, ok := counter(item.Value)
+	return !ok
+}
+
+// StoresBytes marks memcached as a byte storage
+func (m Memcache) StoresBytes() {}
+
 // Flush flushes memcached storage
 func (m Memcache) Flush() error {
 	return m.client.DeleteAll()
@@ -0,0 +1,217 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+// fakeMemcached is an in process server speaking the subset of the
+// memcached text protocol used by gomemcache
+type fakeMemcached struct {
+	mu    sync.Mutex
+	items map[string]fakeMemcachedItem
+	cas   uint64
+}
+
+type fakeMemcachedItem struct {
+	value   []byte
+	flags   string
+	cas     uint64
+	expires time.Time
+}
+
+// memcached starts a fake memcached server and returns its address
+func memcached(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	m := &fakeMemcached{items: make(map[string]fakeMemcachedItem)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go m.serve(conn)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func (m *fakeMemcached) serve(conn net.Conn) {
+	defer conn.Close()
+
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+
+		var data []byte
+		switch args[0] {
+		case "set", "add", "replace", "cas":
+			n, _ := strconv.Atoi(args[4])
+			data = make([]byte, n+2)
+			if _, err := readFull(rw, data); err != nil {
+				return
+			}
+			data = data[:n]
+		}
+
+		m.mu.Lock()
+		m.handle(rw, args, data)
+		m.mu.Unlock()
+		if rw.Flush() != nil {
+			return
+		}
+	}
+}
+
+func readFull(rw *bufio.ReadWriter, data []byte) (int, error) {
+	n := 0
+	for n < len(data) {
+		k, err := rw.Read(data[n:])
+		if err != nil {
+			return n, err
+		}
+		n += k
+	}
+	return n, nil
+}
+
+func (m *fakeMemcached) get(key string) (fakeMemcachedItem, bool) {
+	it, ok := m.items[key]
+	if ok && !it.expires.IsZero() && !it.expires.After(time.Now()) {
+		delete(m.items, key)
+		return it, false
+	}
+	return it, ok
+}
+
+func (m *fakeMemcached) handle(w *bufio.ReadWriter, args []string, data []byte) {
+	switch args[0] {
+	case "get", "gets":
+		for _, key := range args[1:] {
+			if it, ok := m.get(key); ok {
+				fmt.Fprintf(w, "VALUE %s %s %d %d\r\n%s\r\n", key, it.flags, len(it.value), it.cas, it.value)
+			}
+		}
+		fmt.Fprint(w, "END\r\n")
+	case "set", "add", "replace", "cas":
+		key := args[1]
+		it, exists := m.get(key)
+		switch {
+		case args[0] == "add" && exists:
+			fmt.Fprint(w, "NOT_STORED\r\n")
+			return
+		case args[0] == "replace" && !exists:
+			fmt.Fprint(w, "NOT_STORED\r\n")
+			return
+		case args[0] == "cas" && !exists:
+			fmt.Fprint(w, "NOT_FOUND\r\n")
+			return
+		case args[0] == "cas" && args[5] != strconv.FormatUint(it.cas, 10):
+			fmt.Fprint(w, "EXISTS\r\n")
+			return
+		}
+		m.cas++
+		m.items[key] = fakeMemcachedItem{value: data, flags: args[2], cas: m.cas, expires: memcachedExpiry(args[3])}
+		fmt.Fprint(w, "STORED\r\n")
+	case "incr", "decr":
+		it, ok := m.get(args[1])
+		if !ok {
+			fmt.Fprint(w, "NOT_FOUND\r\n")
+			return
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(string(it.value)), 10, 64)
+		if err != nil {
+			fmt.Fprint(w, "CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+			return
+		}
+		delta, _ := strconv.ParseUint(args[2], 10, 64)
+		if args[0] == "incr" {
+			n += delta
+		} else if delta > n {
+			n = 0
+		} else {
+			n -= delta
+		}
+		m.cas++
+		it.value, it.cas = []byte(strconv.FormatUint(n, 10)), m.cas
+		m.items[args[1]] = it
+		fmt.Fprintf(w, "%d\r\n", n)
+	case "touch":
+		it, ok := m.get(args[1])
+		if !ok {
+			fmt.Fprint(w, "NOT_FOUND\r\n")
+			return
+		}
+		it.expires = memcachedExpiry(args[2])
+		m.items[args[1]] = it
+		fmt.Fprint(w, "TOUCHED\r\n")
+	case "delete":
+		if _, ok := m.get(args[1]); !ok {
+			fmt.Fprint(w, "NOT_FOUND\r\n")
+			return
+		}
+		delete(m.items, args[1])
+		fmt.Fprint(w, "DELETED\r\n")
+	case "flush_all":
+		m.items = make(map[string]fakeMemcachedItem)
+		fmt.Fprint(w, "OK\r\n")
+	default:
+		fmt.Fprint(w, "ERROR\r\n")
+	}
+}
+
+// memcachedExpiry converts a memcached expiration time, which is relative unless
+// longer than 30 days
+func memcachedExpiry(s string) time.Time {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	switch {
+	case n == 0:
+		return time.Time{}
+	case n < 0:
+		return time.Unix(0, 1)
+	case n > 30*24*60*60:
+		return time.Unix(n, 0)
+	}
+	return time.Now().Add(time.Duration(n) * time.Second)
+}
+
+func TestMemcached_ReadWrite(t *testing.T) {
+	s := Memcached(memcached(t))
+
+	assert.NilError(t, s.Write("key1", []byte("val1"), time.Minute))
+	assert.NilError(t, s.Write("key2", map[string]int{"a": 1}, 0))
+
+	v, err := s.Read("key1")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []byte("val1"), v)
+
+	v, err = s.Read("key2")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []byte(`{"a":1}`), v)
+
+	assert.NilError(t, s.Delete("key1"))
+	_, err = s.Read("key1")
+	assert.ErrorType(t, err, ErrKeyNotExist)
+
+	assert.NilError(t, s.Flush())
+	_, err = s.Read("key2")
+	assert.ErrorType(t, err, ErrKeyNotExist)
+}
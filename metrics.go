@@ -0,0 +1,340 @@
+package cache
+
+import (
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Op is an operation of a cache on a storage
+type Op string
+
+const (
+	// OpRead reads keys
+	OpRead Op = "read"
+
+	// OpWrite writes keys
+	OpWrite Op = "write"
+
+	// OpDelete deletes keys
+	OpDelete Op = "delete"
+
+	// OpPropagate copies keys found in a storage into a higher one
+	// missing them
+	OpPropagate Op = "propagate"
+)
+
+// latencyBounds are the upper bounds of the latency histogram buckets
+var latencyBounds = []time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// Observation describes a call of a cache to one of its storage
+type Observation struct {
+	// Storage is the name of the storage, which is its type name, and
+	// Priority its priority
+	Storage  string
+	Priority Priority
+
+	Op Op
+
+	// Keys is the number of keys of the call and Hits the number of
+	// them found by a read
+	Keys int
+	Hits int
+
+	Err      error
+	Duration time.Duration
+
+	// Bytes is the size of the values read or written from or to a byte
+	// storage
+	Bytes int
+}
+
+// Metrics receives the calls of a cache to its storage, e.g. to export
+// them. Any struct implementing Metrics can be passed to
+// cache.New(WithMetrics(...)). Observe is called concurrently
+type Metrics interface {
+	Observe(o Observation)
+}
+
+// Stats is a snapshot of the calls of a cache to its storage
+type Stats struct {
+	// Storage are the stats of every storage in the order they're
+	// looped over
+	Storage []StorageStats
+}
+
+// Priority sums the stats of the storage of the given priority
+func (s Stats) Priority(p Priority) StorageStats {
+	sum := StorageStats{Priority: p, Latency: make(map[Op]Histogram)}
+	for _, st := range s.Storage {
+		if st.Priority != p {
+			continue
+		}
+		sum.Hits += st.Hits
+		sum.Misses += st.Misses
+		sum.Errors += st.Errors
+		sum.Writes += st.Writes
+		sum.Deletes += st.Deletes
+		sum.Propagations += st.Propagations
+		sum.Evictions += st.Evictions
+		sum.BytesRead += st.BytesRead
+		sum.BytesWritten += st.BytesWritten
+		for op, h := range st.Latency {
+			sum.Latency[op] = sum.Latency[op].add(h)
+		}
+	}
+	return sum
+}
+
+// StorageStats are the stats of a storage. Hits, misses, writes, deletes
+// and propagations are counted per key, errors per call
+type StorageStats struct {
+	Name     string
+	Priority Priority
+
+	Hits         uint64
+	Misses       uint64
+	Errors       uint64
+	Writes       uint64
+	Deletes      uint64
+	Propagations uint64
+
+	// Evictions is the number of entries evicted by a bounded storage,
+	// e.g. InMem
+	Evictions uint64
+
+	BytesRead    uint64
+	BytesWritten uint64
+
+	// Latency are the latencies of the calls per operation
+	Latency map[Op]Histogram
+}
+
+// HitRatio returns the share of the keys read which have been found
+func (s StorageStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Histogram counts durations in buckets
+type Histogram struct {
+	// Bounds are the upper bounds of the buckets and Counts the number
+	// of durations in every bucket, the last one counting the durations
+	// above all the bounds
+	Bounds []time.Duration
+	Counts []uint64
+
+	Count uint64
+	Sum   time.Duration
+}
+
+func (h Histogram) add(o Histogram) Histogram {
+	if h.Bounds == nil {
+		h.Bounds, h.Counts = o.Bounds, make([]uint64, len(o.Counts))
+	}
+	for i := range o.Counts {
+		h.Counts[i] += o.Counts[i]
+	}
+	h.Count += o.Count
+	h.Sum += o.Sum
+	return h
+}
+
+// histogram is a Histogram updated atomically
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    int64
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]uint64, len(latencyBounds)+1)}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(latencyBounds) && d > latencyBounds[i] {
+		i++
+	}
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddInt64(&h.sum, int64(d))
+}
+
+func (h *histogram) snapshot() Histogram {
+	s := Histogram{
+		Bounds: latencyBounds,
+		Counts: make([]uint64, len(h.counts)),
+		Count:  atomic.LoadUint64(&h.count),
+		Sum:    time.Duration(atomic.LoadInt64(&h.sum)),
+	}
+	for i := range h.counts {
+		s.Counts[i] = atomic.LoadUint64(&h.counts[i])
+	}
+	return s
+}
+
+// storageStats collects the stats of a storage
+type storageStats struct {
+	name     string
+	priority Priority
+
+	hits, misses, errors          uint64
+	writes, deletes, propagations uint64
+	bytesRead, bytesWritten       uint64
+	latency                       map[Op]*histogram
+}
+
+func (s *storageStats) Observe(o Observation) {
+	if o.Err != nil {
+		atomic.AddUint64(&s.errors, 1)
+	} else {
+		switch o.Op {
+		case OpRead:
+			atomic.AddUint64(&s.hits, uint64(o.Hits))
+			atomic.AddUint64(&s.misses, uint64(o.Keys-o.Hits))
+			atomic.AddUint64(&s.bytesRead, uint64(o.Bytes))
+		case OpWrite:
+			atomic.AddUint64(&s.writes, uint64(o.Keys))
+			atomic.AddUint64(&s.bytesWritten, uint64(o.Bytes))
+		case OpDelete:
+			atomic.AddUint64(&s.deletes, uint64(o.Keys))
+		case OpPropagate:
+			atomic.AddUint64(&s.propagations, uint64(o.Keys))
+		}
+	}
+	if h, ok := s.latency[o.Op]; ok {
+		h.observe(o.Duration)
+	}
+}
+
+func (s *storageStats) snapshot() StorageStats {
+	st := StorageStats{
+		Name:         s.name,
+		Priority:     s.priority,
+		Hits:         atomic.LoadUint64(&s.hits),
+		Misses:       atomic.LoadUint64(&s.misses),
+		Errors:       atomic.LoadUint64(&s.errors),
+		Writes:       atomic.LoadUint64(&s.writes),
+		Deletes:      atomic.LoadUint64(&s.deletes),
+		Propagations: atomic.LoadUint64(&s.propagations),
+		BytesRead:    atomic.LoadUint64(&s.bytesRead),
+		BytesWritten: atomic.LoadUint64(&s.bytesWritten),
+		Latency:      make(map[Op]Histogram, len(s.latency)),
+	}
+	for op, h := range s.latency {
+		st.Latency[op] = h.snapshot()
+	}
+	return st
+}
+
+// evicter is a storage evicting entries, e.g. InMem
+type evicter interface {
+	Evictions() uint64
+}
+
+// tier is a registered storage along with its stats
+type tier struct {
+	s     Storage
+	stats *storageStats
+}
+
+// tiers returns the registered storage in the order they're looped over
+func tiers(storage map[Priority][]Storage) []tier {
+	var tiers []tier
+	for _, p := range []Priority{PriorityHigh, PriorityMedium} {
+		for _, s := range storage[p] {
+			stats := &storageStats{
+				name:     storageName(s),
+				priority: p,
+				latency:  make(map[Op]*histogram),
+			}
+			for _, op := range []Op{OpRead, OpWrite, OpDelete, OpPropagate} {
+				stats.latency[op] = newHistogram()
+			}
+			tiers = append(tiers, tier{s: s, stats: stats})
+		}
+	}
+	return tiers
+}
+
+// storageName names a storage after its type, e.g. inmem or redis
+func storageName(s Storage) string {
+	t := reflect.TypeOf(s)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return strings.ToLower(t.Name())
+}
+
+// Stats returns a snapshot of the calls of the cache to its storage
+func (c *Cache) Stats() Stats {
+	stats := Stats{Storage: make([]StorageStats, len(c.tiers))}
+	for i, t := range c.tiers {
+		stats.Storage[i] = t.stats.snapshot()
+		if e, ok := t.s.(evicter); ok {
+			stats.Storage[i].Evictions = e.Evictions()
+		}
+	}
+	return stats
+}
+
+// observe records a call to s of op on keys keys, hits of which have been
+// found, which took d. Missing keys are no errors
+func (c *Cache) observe(s Storage, op Op, d time.Duration, keys, hits, bytes int, err error) {
+	if b, ok := s.(bound); ok {
+		s = b.s
+	}
+	if err == ErrKeyNotExist {
+		err = nil
+	}
+	for _, t := range c.tiers {
+		if !same(s, t.s) {
+			continue
+		}
+
+		o := Observation{
+			Storage:  t.stats.name,
+			Priority: t.stats.priority,
+			Op:       op,
+			Keys:     keys,
+			Hits:     hits,
+			Err:      err,
+			Duration: d,
+			Bytes:    bytes,
+		}
+		t.stats.Observe(o)
+		for _, m := range c.metrics {
+			m.Observe(o)
+		}
+		return
+	}
+}
+
+// sizeOf returns the size of a value read from or written to a byte storage
+func sizeOf(v interface{}) int {
+	switch x := v.(type) {
+	case []byte:
+		return len(x)
+	case string:
+		return len(x)
+	}
+	return 0
+}
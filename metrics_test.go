@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+type recorder struct {
+	sync.Mutex
+	observations []Observation
+}
+
+func (r *recorder) Observe(o Observation) {
+	r.Lock()
+	defer r.Unlock()
+	r.observations = append(r.observations, o)
+}
+
+func TestCache_Stats(t *testing.T) {
+	var (
+		high   = InMemory()
+		medium = Memcached(memcached(t))
+		r      = &recorder{}
+	)
+	c := New(WithHighPriorityStorage(high), WithMediumPriorityStorage(medium), WithMetrics(r))
+
+	assert.NilError(t, c.Set("key1", "abc", 0))
+	assert.NilError(t, high.Delete("go:cache:key1"))
+
+	var v string
+	assert.NilError(t, c.Get("key1", &v))
+	assert.NilError(t, c.Get("key1", &v))
+	assert.ErrorContains(t, c.Get("key2", &v), ErrKeyNotExist.Error())
+
+	var m map[string]string
+	assert.NilError(t, c.GetMulti([]string{"key1", "key2"}, &m))
+	assert.NilError(t, c.Del("key1"))
+
+	stats := c.Stats()
+	assert.Equal(t, 2, len(stats.Storage))
+
+	s := stats.Storage[0]
+	assert.Equal(t, "inmem", s.Name)
+	assert.Equal(t, PriorityHigh, s.Priority)
+	assert.Equal(t, uint64(2), s.Hits)
+	assert.Equal(t, uint64(3), s.Misses)
+	assert.Equal(t, uint64(2), s.Writes)
+	assert.Equal(t, uint64(1), s.Propagations)
+	assert.Equal(t, uint64(1), s.Deletes)
+	assert.Equal(t, uint64(0), s.BytesRead)
+	assert.Equal(t, uint64(4), s.Latency[OpRead].Count)
+
+	s = stats.Storage[1]
+	assert.Equal(t, "memcache", s.Name)
+	assert.Equal(t, PriorityMedium, s.Priority)
+	assert.Equal(t, uint64(1), s.Hits)
+	assert.Equal(t, uint64(2), s.Misses)
+	assert.Equal(t, uint64(1), s.Writes)
+	assert.Assert(t, s.BytesRead > 0 && s.BytesWritten > 0)
+
+	assert.Equal(t, uint64(2), stats.Priority(PriorityHigh).Hits)
+	assert.Equal(t, 1.0/3, stats.Priority(PriorityMedium).HitRatio())
+
+	var reads int
+	for _, o := range r.observations {
+		if o.Op == OpRead {
+			reads++
+		}
+	}
+	assert.Equal(t, 7, reads)
+}
@@ -1,7 +1,10 @@
 package cache
 
 import (
-	"github.com/sirupsen/logrus"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Option is the type of constructor options for New(...).
@@ -36,10 +39,19 @@ func withPriorityStorage(priority Priority, storage ...Storage) Option {
 	}
 }
 
-// WithDebug configures a cache instance with debug flag on
+// WithDebug configures a cache instance with debug flag on, which has
+// the default logger log debug events as well
 func WithDebug() Option {
 	return func(c *Cache) {
-		c.logger.SetLevel(logrus.DebugLevel)
+		c.level.Set(slog.LevelDebug)
+	}
+}
+
+// WithLogger configures a cache instance with a custom cache.Logger
+// instead of the default one, which logs text to stderr
+func WithLogger(logger Logger) Option {
+	return func(c *Cache) {
+		c.logger = logger
 	}
 }
 
@@ -56,3 +68,79 @@ func WithNamespace(ns string) Option {
 		c.ns = ns
 	}
 }
+
+// WithCodec configures a cache instance with the codec used to encode
+// values written to byte storage, e.g. Redis or memcached. JSON is used
+// by default. Values encoded with any other built-in codec or the given
+// one can still be read
+func WithCodec(codec Codec) Option {
+	return func(c *Cache) {
+		c.enc.codec = codec
+	}
+}
+
+// WithCompression configures a cache instance to compress values written
+// to byte storage once they are at least threshold bytes long encoded.
+// Values compressed with any built-in compressor or the given one as well
+// as uncompressed values can still be read
+func WithCompression(compressor Compressor, threshold int) Option {
+	return func(c *Cache) {
+		c.enc.compressor = compressor
+		c.enc.threshold = threshold
+	}
+}
+
+// WithMetrics configures a cache instance to report its calls to its
+// storage to the given metrics as well, they're always recorded for
+// Stats
+func WithMetrics(metrics ...Metrics) Option {
+	return func(c *Cache) {
+		c.metrics = append(c.metrics, metrics...)
+	}
+}
+
+// WithTracerProvider configures a cache instance to emit OpenTelemetry
+// spans for Get, Set, Del, ByTag and DelByTag along with child spans for
+// every storage visited
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *Cache) {
+		c.tracer = tp.Tracer(tracerName)
+	}
+}
+
+// WithHashedSpanKeys configures a cache instance to record the SHA-256
+// hashes of the keys in spans instead of the keys, e.g. when they contain
+// personal data
+func WithHashedSpanKeys() Option {
+	return func(c *Cache) {
+		c.hashSpanKeys = true
+	}
+}
+
+// WithEarlyExpiration configures a cache instance to have GetOrLoad reload
+// values before they expire, with a probability growing as they get
+// closer to expiring and the longer they have taken to load so that a
+// single caller reloads them ahead of the others. A beta of 1 is a good
+// default, greater values reload earlier
+func WithEarlyExpiration(beta float64) Option {
+	return func(c *Cache) {
+		c.beta = beta
+	}
+}
+
+// WithStaleWhileRevalidate configures a cache instance to keep expiring
+// items for d longer. GetOrLoad serves them during that time and reloads
+// them in the background, Get serves them as they are
+func WithStaleWhileRevalidate(d time.Duration) Option {
+	return func(c *Cache) {
+		c.staleWhileRevalidate = d
+	}
+}
+
+// WithStaleIfError configures a cache instance to keep expiring items for
+// d longer so that GetOrLoad can serve them if the loader fails
+func WithStaleIfError(d time.Duration) Option {
+	return func(c *Cache) {
+		c.staleIfError = d
+	}
+}
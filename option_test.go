@@ -1,9 +1,9 @@
 package cache
 
 import (
+	"log/slog"
 	"testing"
 
-	"github.com/sirupsen/logrus"
 	"gotest.tools/assert"
 )
 
@@ -26,7 +26,7 @@ func Test_WithNamespace(t *testing.T) {
 
 func Test_WithDebug(t *testing.T) {
 	c := New(WithDebug())
-	assert.Equal(t, c.logger.Level, logrus.DebugLevel)
+	assert.Equal(t, c.level.Level(), slog.LevelDebug)
 }
 
 func Test_WithStorageEmptySet(t *testing.T) {
@@ -1,33 +1,140 @@
 package cache
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"strconv"
 	"time"
 
-	redisClient "github.com/go-redis/redis"
+	redisClient "github.com/redis/go-redis/v9"
 )
 
+// redisScanCount is the number of keys asked for per SCAN call by Flush
+const redisScanCount = 1000
+
+// compareAndSetScript sets a key if the sha1 of its value still starts
+// with the given version, returning 1 if it has been set, 0 if the
+// version differs and -1 if the key does not exist
+//
+// KEYS[1] the key
+// ARGV[1] the version as 16 hex digits, ARGV[2] the value, ARGV[3] the ttl
+// in milliseconds or 0
+var compareAndSetScript = redisClient.NewScript(`
+local v = redis.call('GET', KEYS[1])
+if not v then
+	return -1
+end
+if string.sub(redis.sha1hex(v), 1, 16) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// compareAndDeleteScript deletes a key if the sha1 of its value still
+// starts with the given version, returning the same as compareAndSetScript
+//
+// KEYS[1] the key
+// ARGV[1] the version as 16 hex digits
+var compareAndDeleteScript = redisClient.NewScript(`
+local v = redis.call('GET', KEYS[1])
+if not v then
+	return -1
+end
+if string.sub(redis.sha1hex(v), 1, 16) ~= ARGV[1] then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+return 1
+`)
+
+// incrScript increments a counter, setting the ttl of counters it creates.
+// It returns false if the key holds anything but an integer
+//
+// KEYS[1] the key
+// ARGV[1] the delta, ARGV[2] the ttl in milliseconds or 0
+var incrScript = redisClient.NewScript(`
+local v = redis.call('GET', KEYS[1])
+if v and not string.match(v, '^-?%d+$') then
+	return false
+end
+local n = redis.call('INCRBY', KEYS[1], ARGV[1])
+if not v and tonumber(ARGV[2]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return n
+`)
+
 type redis struct {
-	client *redisClient.Client
+	client redisClient.UniversalClient
+	ns     string
+
+	// fixed is set if the namespace has been configured explicitly, and
+	// mismatch once it turns out to differ from the one of the cache
+	fixed    bool
+	mismatch error
 }
 
-func Redis(options *redisClient.Options) Storage {
-	clnt := redisClient.NewClient(options)
-	return redis{
-		client: clnt,
+// RedisOption is the type of constructor options for RedisClient(...)
+type RedisOption func(r *redis)
+
+// WithRedisNamespace configures the namespace Flush deletes the keys of,
+// which is "go:cache" by default. Storage passed to New take the namespace
+// of the cache unless configured with another one, in which case Flush
+// fails as it wouldn't delete any of the keys of the cache
+func WithRedisNamespace(ns string) RedisOption {
+	return func(r *redis) {
+		r.ns, r.fixed = ns, true
 	}
 }
 
+// Redis creates a new storage on a single redis node which can be passed
+// to cache.New(WithStorage(...))
+func Redis(options *redisClient.Options, opts ...RedisOption) Storage {
+	return RedisClient(redisClient.NewClient(options), opts...)
+}
+
+// RedisClient creates a new storage using the given client, which can be
+// a standalone, Sentinel failover, Cluster or Ring client, e.g. created
+// with redis.NewUniversalClient
+func RedisClient(client redisClient.UniversalClient, options ...RedisOption) Storage {
+	r := redis{client: client, ns: "go:cache"}
+	for _, option := range options {
+		option(&r)
+	}
+	return r
+}
+
 func (r redis) Write(key string, v interface{}, expiration time.Duration) error {
-	b, err := json.Marshal(v)
+	return r.WriteContext(context.Background(), key, v, expiration)
+}
+
+func (r redis) Read(key string) (interface{}, error) {
+	return r.ReadContext(context.Background(), key)
+}
+
+func (r redis) Delete(key string) error {
+	return r.DeleteContext(context.Background(), key)
+}
+
+// WriteContext sets the key. The deadline and cancellation of ctx are
+// passed along to the client, as by every other command
+func (r redis) WriteContext(ctx context.Context, key string, v interface{}, expiration time.Duration) error {
+	b, err := marshal(v)
 	if err != nil {
 		return err
 	}
-	return r.client.Set(key, string(b), expiration).Err()
+	return r.client.Set(ctx, key, string(b), expiration).Err()
 }
 
-func (r redis) Read(key string) (interface{}, error) {
-	result := r.client.Get(key)
+func (r redis) ReadContext(ctx context.Context, key string) (interface{}, error) {
+	result := r.client.Get(ctx, key)
 	if result.Err() != nil {
 		if result.Err() == redisClient.Nil {
 			return nil, ErrKeyNotExist
@@ -37,10 +144,224 @@ func (r redis) Read(key string) (interface{}, error) {
 	return result.Result()
 }
 
-func (r redis) Delete(key string) error {
-	return r.client.Del(key).Err()
+func (r redis) DeleteContext(ctx context.Context, key string) error {
+	return r.client.Del(ctx, key).Err()
 }
 
+func (r redis) StoresBytes() {}
+
+// Flush deletes the keys of the namespace with SCAN and UNLINK on every
+// master node, leaving the rest of the database untouched
 func (r redis) Flush() error {
-	return r.Flush()
+	return r.FlushContext(context.Background())
+}
+
+func (r redis) FlushContext(ctx context.Context) error {
+	if r.mismatch != nil {
+		return r.mismatch
+	}
+
+	switch c := r.client.(type) {
+	case *redisClient.ClusterClient:
+		return c.ForEachMaster(ctx, r.flushNode)
+	case *redisClient.Ring:
+		return c.ForEachShard(ctx, r.flushNode)
+	}
+	return r.flush(ctx, r.client)
+}
+
+func (r redis) flushNode(ctx context.Context, c *redisClient.Client) error {
+	return r.flush(ctx, c)
+}
+
+func (r redis) flush(ctx context.Context, c redisClient.Cmdable) error {
+	var cursor uint64
+	for {
+		keys, next, err := c.Scan(ctx, cursor, r.ns+":*", redisScanCount).Result()
+		if err != nil {
+			return err
+		}
+
+		if len(keys) > 0 {
+			// keys of different slots can't be unlinked at once on a cluster
+			pipe := c.Pipeline()
+			for _, key := range keys {
+				pipe.Unlink(ctx, key)
+			}
+			if _, err := pipe.Exec(ctx); err != nil {
+				return err
+			}
+		}
+
+		if cursor = next; cursor == 0 {
+			return nil
+		}
+	}
+}
+
+func (r redis) withNamespace(ns string) Storage {
+	switch {
+	case !r.fixed:
+		r.ns = ns
+	case r.ns != ns:
+		r.mismatch = fmt.Errorf("redis namespace %q differs from the cache namespace %q", r.ns, ns)
+	}
+	return r
+}
+
+// Close closes the client
+func (r redis) Close() error {
+	return r.client.Close()
+}
+
+// cluster reports whether multi-key commands have to be split by slot
+func (r redis) cluster() bool {
+	_, ok := r.client.(*redisClient.ClusterClient)
+	return ok
+}
+
+func (r redis) ReadMulti(ctx context.Context, keys ...string) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(keys))
+	if r.cluster() {
+		pipe := r.client.Pipeline()
+		cmds := make([]*redisClient.StringCmd, len(keys))
+		for i, key := range keys {
+			cmds[i] = pipe.Get(ctx, key)
+		}
+		if _, err := pipe.Exec(ctx); err != nil && err != redisClient.Nil {
+			return nil, err
+		}
+		for i, cmd := range cmds {
+			if cmd.Err() == nil {
+				values[keys[i]] = cmd.Val()
+			}
+		}
+		return values, nil
+	}
+
+	result, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range result {
+		if v != nil {
+			values[keys[i]] = v
+		}
+	}
+	return values, nil
+}
+
+func (r redis) WriteMulti(ctx context.Context, values map[string]interface{}, expiration time.Duration) error {
+	pipe := r.client.Pipeline()
+	for key, v := range values {
+		b, err := marshal(v)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, key, string(b), expiration)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r redis) DeleteMulti(ctx context.Context, keys ...string) error {
+	if !r.cluster() {
+		return r.client.Del(ctx, keys...).Err()
+	}
+
+	pipe := r.client.Pipeline()
+	for _, key := range keys {
+		pipe.Del(ctx, key)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Add sets the key with SET NX
+func (r redis) Add(ctx context.Context, key string, v interface{}, expiration time.Duration) error {
+	b, err := marshal(v)
+	if err != nil {
+		return err
+	}
+
+	ok, err := r.client.SetNX(ctx, key, string(b), expiration).Result()
+	if err == nil && !ok {
+		return ErrKeyExists
+	}
+	return err
+}
+
+// Replace sets the key with SET XX
+func (r redis) Replace(ctx context.Context, key string, v interface{}, expiration time.Duration) error {
+	b, err := marshal(v)
+	if err != nil {
+		return err
+	}
+
+	ok, err := r.client.SetXX(ctx, key, string(b), expiration).Result()
+	if err == nil && !ok {
+		return ErrKeyNotExist
+	}
+	return err
+}
+
+// ReadVersion reads the key along with its version, which is derived
+// from the sha1 of the value as redis doesn't version keys
+func (r redis) ReadVersion(ctx context.Context, key string) (interface{}, uint64, error) {
+	v, err := r.ReadContext(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	sum := sha1.Sum([]byte(v.(string)))
+	return v, binary.BigEndian.Uint64(sum[:8]), nil
+}
+
+// CompareAndWrite sets the key in a script if its value is still the
+// one of the given version. Values written by Cache record the time they
+// have been written, so rewriting a key changes its version even if the
+// value is the same
+func (r redis) CompareAndWrite(ctx context.Context, key string, version uint64, v interface{}, expiration time.Duration) error {
+	b, err := marshal(v)
+	if err != nil {
+		return err
+	}
+
+	args := []interface{}{
+		fmt.Sprintf("%016x", version),
+		string(b),
+		strconv.FormatInt(int64(expiration/time.Millisecond), 10),
+	}
+	return compared(compareAndSetScript.Run(ctx, r.client, []string{key}, args...))
+}
+
+// CompareAndDelete deletes the key in a script if its value is still the
+// one of the given version
+func (r redis) CompareAndDelete(ctx context.Context, key string, version uint64) error {
+	return compared(compareAndDeleteScript.Run(ctx, r.client, []string{key}, fmt.Sprintf("%016x", version)))
+}
+
+// compared maps the result of a compare script to an error
+func compared(cmd *redisClient.Cmd) error {
+	n, err := cmd.Int64()
+	if err != nil {
+		return err
+	}
+	switch n {
+	case -1:
+		return ErrKeyNotExist
+	case 0:
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+// Incr increments the counter at key with INCRBY in a script setting the
+// ttl of the counters it creates
+func (r redis) Incr(ctx context.Context, key string, delta int64, expiration time.Duration) (int64, error) {
+	ttl := strconv.FormatInt(int64(expiration/time.Millisecond), 10)
+	n, err := incrScript.Run(ctx, r.client, []string{key}, delta, ttl).Int64()
+	if err == redisClient.Nil {
+		return 0, ErrNotCounter
+	}
+	return n, err
 }
@@ -0,0 +1,275 @@
+package cache
+
+import (
+	"context"
+	"sort"
+
+	redisClient "github.com/redis/go-redis/v9"
+)
+
+// untagScript removes tags from a key. It returns -1 if all the tags of
+// the key are to be removed but they have changed since they've been read
+//
+// KEYS[1] the set of tags of the key, KEYS[2:] the sets of keys of the tags
+// ARGV[1] the key, ARGV[2] 1 if all the tags of the key are removed,
+// ARGV[3:] the tags in the order of KEYS[2:]
+var untagScript = redisClient.NewScript(`
+if ARGV[2] == '1' then
+	if redis.call('SCARD', KEYS[1]) ~= #KEYS - 1 then
+		return -1
+	end
+	for i = 3, #ARGV do
+		if redis.call('SISMEMBER', KEYS[1], ARGV[i]) == 0 then
+			return -1
+		end
+	end
+end
+
+for i = 2, #KEYS do
+	redis.call('SREM', KEYS[i], ARGV[1])
+	redis.call('SREM', KEYS[1], ARGV[i + 1])
+end
+return #KEYS - 1
+`)
+
+// deleteTaggedScript deletes the keys tagged with a tag and untags them
+// from all their tags. It returns false if the keys of the tag or their
+// tags have changed since they've been read
+//
+// KEYS[1] the set of keys of the tag, KEYS[2:n+1] the n tagged keys,
+// KEYS[n+2:2n+1] their sets of tags, KEYS[2n+2:] the sets of keys of
+// their other tags
+// ARGV[1] the tag, ARGV[2] n, ARGV[3:n+2] the tagged keys as stored in the
+// sets, ARGV[n+3:] the other tags in the order of KEYS[2n+2:]
+var deleteTaggedScript = redisClient.NewScript(`
+local n = tonumber(ARGV[2])
+if redis.call('SCARD', KEYS[1]) ~= n then
+	return false
+end
+
+local known = {}
+for i = n + 3, #ARGV do
+	known[ARGV[i]] = true
+end
+for i = 1, n do
+	if redis.call('SISMEMBER', KEYS[1], ARGV[i + 2]) == 0 then
+		return false
+	end
+	for _, tag in ipairs(redis.call('SMEMBERS', KEYS[n + 1 + i])) do
+		if tag ~= ARGV[1] and not known[tag] then
+			return false
+		end
+	end
+end
+
+for i = 1, n do
+	redis.call('UNLINK', KEYS[1 + i], KEYS[n + 1 + i])
+	for j = 2 * n + 2, #KEYS do
+		redis.call('SREM', KEYS[j], ARGV[i + 2])
+	end
+end
+redis.call('UNLINK', KEYS[1])
+return n
+`)
+
+// redisTagger keeps the tags of a key and the keys of a tag as redis
+// sets, updating them atomically
+type redisTagger struct {
+	ns  string
+	std std
+}
+
+// RedisTagger creates a Tagger storing tags as sets on the Redis storage
+// they're used with, which can be passed to cache.New(WithTagger(...)).
+// Tag and UnTag are atomic and DelByTag is a single server side script.
+// Tags on any other storage, including Redis Cluster and Ring where the
+// keys of a tag live in different slots or shards, are kept the same way
+// as by the default tagger
+func RedisTagger(ns string) Tagger {
+	return redisTagger{
+		ns:  ns,
+		std: std{ns: ns, logger: nopLogger{}},
+	}
+}
+
+func (t redisTagger) withLogger(logger Logger) Tagger {
+	t.std.logger = logger
+	return t
+}
+
+func (t redisTagger) nsKey(key string) string {
+	return t.ns + ":" + key
+}
+
+// tagsKey returns the key of the set of tags of key
+func (t redisTagger) tagsKey(key string) string {
+	return t.nsKey("key:" + key + ":tags")
+}
+
+// keysKey returns the key of the set of keys of tag
+func (t redisTagger) keysKey(tag string) string {
+	return t.nsKey("tag:" + tag + ":keys")
+}
+
+// client returns the client of s if it is a redis storage on a single
+// node, along with the context s is bound to
+func (t redisTagger) client(s Storage) (context.Context, redisClient.UniversalClient, bool) {
+	ctx := context.Background()
+	if b, ok := s.(bound); ok {
+		ctx, s = b.ctx, b.s
+	}
+
+	r, ok := s.(redis)
+	if !ok {
+		return nil, nil, false
+	}
+	switch r.client.(type) {
+	case *redisClient.ClusterClient, *redisClient.Ring:
+		return nil, nil, false
+	}
+	return ctx, r.client, true
+}
+
+func (t redisTagger) Tag(s Storage, key string, tags ...string) error {
+	ctx, client, ok := t.client(s)
+	if !ok {
+		return t.std.Tag(s, key, tags...)
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(tags))
+	for i, tag := range tags {
+		args[i] = tag
+	}
+
+	_, err := client.TxPipelined(ctx, func(pipe redisClient.Pipeliner) error {
+		pipe.SAdd(ctx, t.tagsKey(key), args...)
+		for _, tag := range tags {
+			pipe.SAdd(ctx, t.keysKey(tag), key)
+		}
+		return nil
+	})
+	return err
+}
+
+// UnTag removes the given tags, or all the tags if none are given, from
+// key in a script, which is run again if the tags of key change while
+// they're read
+func (t redisTagger) UnTag(s Storage, key string, tags ...string) error {
+	ctx, client, ok := t.client(s)
+	if !ok {
+		return t.std.UnTag(s, key, tags...)
+	}
+
+	all := "0"
+	if len(tags) == 0 {
+		all = "1"
+	}
+	for {
+		if all == "1" {
+			var err error
+			if tags, err = members(ctx, client, t.tagsKey(key)); err != nil {
+				return err
+			}
+		}
+
+		keys := []string{t.tagsKey(key)}
+		args := []interface{}{key, all}
+		for _, tag := range tags {
+			keys = append(keys, t.keysKey(tag))
+			args = append(args, tag)
+		}
+		n, err := untagScript.Run(ctx, client, keys, args...).Int64()
+		if err != nil || n >= 0 {
+			return err
+		}
+	}
+}
+
+func (t redisTagger) Tags(s Storage, key string) ([]string, error) {
+	ctx, client, ok := t.client(s)
+	if !ok {
+		return t.std.Tags(s, key)
+	}
+	return members(ctx, client, t.tagsKey(key))
+}
+
+func (t redisTagger) Keys(s Storage, tag string) ([]string, error) {
+	ctx, client, ok := t.client(s)
+	if !ok {
+		return t.std.Keys(s, tag)
+	}
+	return members(ctx, client, t.keysKey(tag))
+}
+
+// DeleteTagged deletes the keys tagged with tag in a single script, which
+// is run again if the keys of tag or their tags change while they're read
+func (t redisTagger) DeleteTagged(s Storage, prefix, tag string) ([]string, error) {
+	ctx, client, ok := t.client(s)
+	if !ok {
+		return t.std.deleteTagged(s, prefix, tag)
+	}
+
+	for {
+		keys, err := members(ctx, client, t.keysKey(tag))
+		if err != nil {
+			return nil, err
+		}
+
+		pipe := client.Pipeline()
+		cmds := make([]*redisClient.StringSliceCmd, len(keys))
+		for i, key := range keys {
+			cmds[i] = pipe.SMembers(ctx, t.tagsKey(key))
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+		var others []string
+		for _, cmd := range cmds {
+			for _, other := range cmd.Val() {
+				if other != tag && !contains(others, other) {
+					others = append(others, other)
+				}
+			}
+		}
+
+		scriptKeys := make([]string, 0, 1+2*len(keys)+len(others))
+		scriptKeys = append(scriptKeys, t.keysKey(tag))
+		for _, key := range keys {
+			scriptKeys = append(scriptKeys, prefix+key)
+		}
+		for _, key := range keys {
+			scriptKeys = append(scriptKeys, t.tagsKey(key))
+		}
+		args := make([]interface{}, 0, 2+len(keys)+len(others))
+		args = append(args, tag, len(keys))
+		for _, key := range keys {
+			args = append(args, key)
+		}
+		for _, other := range others {
+			scriptKeys = append(scriptKeys, t.keysKey(other))
+			args = append(args, other)
+		}
+
+		err = deleteTaggedScript.Run(ctx, client, scriptKeys, args...).Err()
+		if err == redisClient.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return keys, nil
+	}
+}
+
+// members returns the sorted members of a set
+func members(ctx context.Context, client redisClient.UniversalClient, key string) ([]string, error) {
+	keys, err := client.SMembers(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	redisClient "github.com/redis/go-redis/v9"
+	"gotest.tools/assert"
+)
+
+func TestRedisTagger(t *testing.T) {
+	m := miniRedis(t)
+	s := Redis(&redisClient.Options{Addr: m.Addr()})
+	tagger := RedisTagger("go:test:tagger")
+
+	assert.NilError(t, tagger.Tag(s, "key1", "tag1", "tag2"))
+	assert.NilError(t, tagger.Tag(s, "key2", "tag2", "tag2"))
+
+	members, err := m.Members("go:test:tagger:tag:tag2:keys")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"key1", "key2"}, members)
+
+	tags, err := tagger.Tags(s, "key1")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"tag1", "tag2"}, tags)
+
+	assert.NilError(t, tagger.UnTag(s, "key1", "tag2"))
+	keys, err := tagger.Keys(s, "tag2")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"key2"}, keys)
+	tags, _ = tagger.Tags(s, "key1")
+	assert.DeepEqual(t, []string{"tag1"}, tags)
+
+	assert.NilError(t, tagger.UnTag(s, "key1"))
+	assert.NilError(t, tagger.UnTag(s, "key2"))
+	assert.DeepEqual(t, []string{}, m.Keys())
+}
+
+func TestRedisTagger_DelByTag(t *testing.T) {
+	var (
+		m        = miniRedis(t)
+		inMemory = InMemory()
+	)
+	c := New(
+		WithStorage(Redis(&redisClient.Options{Addr: m.Addr()}), inMemory),
+		WithTagger(RedisTagger("go:cache:tagger")),
+	)
+
+	assert.NilError(t, c.Set("key1", 1, 0, "tag1", "tag2"))
+	assert.NilError(t, c.Set("key2", 2, 0, "tag1"))
+	assert.NilError(t, c.Set("key3", 3, 0, "tag2"))
+
+	assert.NilError(t, c.DelByTag("tag1"))
+
+	var v int
+	for _, key := range []string{"key1", "key2"} {
+		assert.ErrorContains(t, c.Get(key, &v), ErrKeyNotExist.Error())
+	}
+	assert.NilError(t, c.Get("key3", &v))
+	assert.Equal(t, 3, v)
+
+	members, err := m.Members("go:cache:tagger:tag:tag2:keys")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"key3"}, members)
+	assert.Assert(t, !m.Exists("go:cache:tagger:key:key1:tags"))
+
+	// the in memory storage is tagged the default way
+	var ints []int
+	assert.NilError(t, New(WithStorage(inMemory)).ByTag("tag2", &ints))
+	assert.DeepEqual(t, []int{3}, ints)
+	_, err = inMemory.Read("go:cache:key1")
+	assert.ErrorType(t, err, ErrKeyNotExist)
+}
+
+// scriptKeys records the keys declared by the scripts run by a client
+type scriptKeys struct {
+	keys map[string]bool
+}
+
+func (h *scriptKeys) DialHook(next redisClient.DialHook) redisClient.DialHook { return next }
+
+func (h *scriptKeys) ProcessPipelineHook(next redisClient.ProcessPipelineHook) redisClient.ProcessPipelineHook {
+	return next
+}
+
+func (h *scriptKeys) ProcessHook(next redisClient.ProcessHook) redisClient.ProcessHook {
+	return func(ctx context.Context, cmd redisClient.Cmder) error {
+		if name := cmd.Name(); name == "eval" || name == "evalsha" {
+			args := cmd.Args()
+			for _, key := range args[3 : 3+args[2].(int)] {
+				h.keys[key.(string)] = true
+			}
+		}
+		return next(ctx, cmd)
+	}
+}
+
+// snapshot returns the values and set members of all the keys
+func snapshot(m *miniredis.Miniredis) map[string]string {
+	values := make(map[string]string)
+	for _, key := range m.Keys() {
+		if m.Type(key) == "set" {
+			members, _ := m.Members(key)
+			values[key] = strings.Join(members, ",")
+			continue
+		}
+		values[key], _ = m.Get(key)
+	}
+	return values
+}
+
+func TestRedisTagger_ScriptKeys(t *testing.T) {
+	var (
+		m      = miniRedis(t)
+		client = redisClient.NewClient(&redisClient.Options{Addr: m.Addr()})
+		hook   = &scriptKeys{keys: make(map[string]bool)}
+		tagger = RedisTagger("go:cache:tagger")
+	)
+	client.AddHook(hook)
+	c := New(WithStorage(RedisClient(client)), WithTagger(tagger))
+
+	assert.NilError(t, c.Set("key1", 1, 0, "tag1", "tag2"))
+	assert.NilError(t, c.Set("key2", 2, 0, "tag1"))
+	assert.NilError(t, c.Set("key3", 3, 0, "tag2", "tag3"))
+	assert.NilError(t, c.Set("key4", 4, 0, "tag4"))
+
+	before := snapshot(m)
+	assert.NilError(t, tagger.UnTag(RedisClient(client), "key3"))
+	assert.NilError(t, c.DelByTag("tag1"))
+	after := snapshot(m)
+
+	for key, v := range before {
+		if after[key] != v {
+			assert.Assert(t, hook.keys[key], "%s is touched by a script but not declared", key)
+		}
+	}
+	assert.DeepEqual(t, map[string]string{
+		"go:cache:key4":                 after["go:cache:key4"],
+		"go:cache:tagger:key:key4:tags": "tag4",
+		"go:cache:tagger:tag:tag4:keys": "key4",
+		"go:cache:key3":                 after["go:cache:key3"],
+	}, after)
+}
+
+func TestRedisTagger_Ring(t *testing.T) {
+	m := miniRedis(t)
+	s := RedisClient(redisClient.NewRing(&redisClient.RingOptions{
+		Addrs: map[string]string{"shard1": m.Addr()},
+	}))
+	tagger := RedisTagger("go:test:tagger")
+
+	// the keys of a tag may live on other shards, so tags are kept as lists
+	assert.NilError(t, tagger.Tag(s, "key1", "tag1"))
+	assert.Equal(t, "string", m.Type("go:test:tagger:tag:tag1:keys"))
+	keys, err := tagger.Keys(s, "tag1")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"key1"}, keys)
+}
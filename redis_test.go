@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redisClient "github.com/redis/go-redis/v9"
+	"gotest.tools/assert"
+)
+
+func miniRedis(t *testing.T) *miniredis.Miniredis {
+	m := miniredis.NewMiniRedis()
+	assert.NilError(t, m.Start())
+	t.Cleanup(m.Close)
+	return m
+}
+
+func TestRedis_ReadWrite(t *testing.T) {
+	m := miniRedis(t)
+	s := Redis(&redisClient.Options{Addr: m.Addr()})
+	defer s.(redis).Close()
+
+	assert.NilError(t, s.Write("key1", []byte("val1"), time.Minute))
+	assert.NilError(t, s.Write("key2", map[string]int{"a": 1}, 0))
+
+	v, err := s.Read("key1")
+	assert.NilError(t, err)
+	assert.Equal(t, "val1", v)
+	assert.Equal(t, time.Minute, m.TTL("key1"))
+
+	v, err = s.Read("key2")
+	assert.NilError(t, err)
+	assert.Equal(t, `{"a":1}`, v)
+
+	assert.NilError(t, s.Delete("key1"))
+	_, err = s.Read("key1")
+	assert.ErrorType(t, err, ErrKeyNotExist)
+}
+
+func TestRedis_Flush(t *testing.T) {
+	m := miniRedis(t)
+	s := RedisClient(redisClient.NewUniversalClient(&redisClient.UniversalOptions{
+		Addrs: []string{m.Addr()},
+	}), WithRedisNamespace("go:test"))
+
+	for i := 0; i < 2500; i++ {
+		m.Set(fmt.Sprintf("go:test:key%d", i), "val")
+	}
+	m.Set("go:other:key1", "val")
+	m.Set("key1", "val")
+
+	assert.NilError(t, s.Flush())
+	assert.DeepEqual(t, []string{"go:other:key1", "key1"}, m.Keys())
+}
+
+func TestRedis_FlushCacheNamespace(t *testing.T) {
+	m := miniRedis(t)
+	c := New(WithNamespace("app"), WithStorage(Redis(&redisClient.Options{Addr: m.Addr()})))
+
+	assert.NilError(t, c.Set("key1", 1, 0))
+	m.Set("go:cache:key1", "val")
+	assert.NilError(t, c.Flush())
+	assert.DeepEqual(t, []string{"go:cache:key1"}, m.Keys())
+
+	c = New(WithNamespace("app"), WithStorage(Redis(&redisClient.Options{Addr: m.Addr()}, WithRedisNamespace("other"))))
+	assert.NilError(t, c.Set("key1", 1, 0))
+	assert.ErrorContains(t, c.Flush(), `redis namespace "other" differs from the cache namespace "app"`)
+	assert.Assert(t, m.Exists("app:key1"))
+}
+
+func TestRedis_Multi(t *testing.T) {
+	m := miniRedis(t)
+	s := Redis(&redisClient.Options{Addr: m.Addr()}).(BatchStorage)
+	ctx := context.Background()
+
+	assert.NilError(t, s.WriteMulti(ctx, map[string]interface{}{"key1": "val1", "key2": []byte("val2")}, 0))
+
+	values, err := s.ReadMulti(ctx, "key1", "key2", "key3")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]interface{}{"key1": `"val1"`, "key2": "val2"}, values)
+
+	assert.NilError(t, s.DeleteMulti(ctx, "key1", "key2"))
+	assert.Equal(t, 0, len(m.Keys()))
+}
+
+func TestRedis_Cache(t *testing.T) {
+	m := miniRedis(t)
+	c := New(WithStorage(Redis(&redisClient.Options{Addr: m.Addr()})))
+
+	assert.NilError(t, c.Set("key1", User{City: "Yerevan"}, time.Minute, "tag1"))
+	assert.NilError(t, c.SetMulti(map[string]interface{}{"key2": User{City: "Gyumri"}}, 0, "tag1"))
+
+	var users []User
+	assert.NilError(t, c.ByTag("tag1", &users))
+	assert.Equal(t, 2, len(users))
+
+	var u map[string]User
+	assert.NilError(t, c.GetMulti([]string{"key1", "key2"}, &u))
+	assert.DeepEqual(t, map[string]User{"key1": {City: "Yerevan"}, "key2": {City: "Gyumri"}}, u)
+
+	assert.NilError(t, c.Flush())
+	assert.Equal(t, 0, len(m.Keys()))
+	assert.NilError(t, c.Close())
+}
+
+func TestRedis_Cluster(t *testing.T) {
+	m := miniRedis(t)
+	s := RedisClient(redisClient.NewClusterClient(&redisClient.ClusterOptions{
+		Addrs: []string{m.Addr()},
+	}))
+	defer s.(redis).Close()
+	ctx := context.Background()
+
+	assert.NilError(t, s.(BatchStorage).WriteMulti(ctx, map[string]interface{}{"go:cache:key1": "val1", "go:cache:key2": "val2"}, 0))
+	values, err := s.(BatchStorage).ReadMulti(ctx, "go:cache:key1", "go:cache:key2", "go:cache:key3")
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(values))
+
+	m.Set("key1", "val")
+	assert.NilError(t, s.Flush())
+	assert.DeepEqual(t, []string{"key1"}, m.Keys())
+}
+
+func TestRedis_Context(t *testing.T) {
+	m := miniRedis(t)
+	s := Redis(&redisClient.Options{Addr: m.Addr()}).(redis)
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Assert(t, errors.Is(s.WriteContext(ctx, "key1", []byte("val1"), 0), context.Canceled))
+	assert.Assert(t, !m.Exists("key1"))
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	assert.NilError(t, s.WriteContext(ctx, "key1", []byte("val1"), 0))
+}
@@ -2,63 +2,180 @@ package cache
 
 import (
 	"bytes"
-	"encoding/json"
-	"errors"
+	"context"
+	"fmt"
 	"io/ioutil"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
+// s3ExpiresMeta is the object metadata holding the absolute expiry of
+// the object in unix nanoseconds
+const s3ExpiresMeta = "Cache-Expires"
+
 type s3storage struct {
 	instance *s3.S3
 	bucket   string
+	prefix   string
+}
+
+// S3Option is the type of constructor options for NewS3(...)
+type S3Option func(s *s3storage)
+
+// WithKeyPrefix configures S3 storage to store objects under prefix,
+// which is also the part of the bucket flushed by Flush
+func WithKeyPrefix(prefix string) S3Option {
+	return func(s *s3storage) {
+		s.prefix = prefix
+	}
 }
 
-func NewS3(sess *session.Session, bucket string) Storage {
-	return s3storage{
+// NewS3 creates a new S3 storage storing objects in the given bucket
+// which can be passed to cache.New(WithStorage(...))
+func NewS3(sess *session.Session, bucket string, options ...S3Option) Storage {
+	s := s3storage{
 		instance: s3.New(sess),
 		bucket:   bucket,
 	}
+	for _, option := range options {
+		option(&s)
+	}
+	return s
 }
 
 func (s s3storage) Write(key string, v interface{}, d time.Duration) error {
-	var buf = bytes.NewBuffer(nil)
-	if err := json.NewEncoder(buf).Encode(v); err != nil {
+	return s.WriteContext(context.Background(), key, v, d)
+}
+
+func (s s3storage) WriteContext(ctx context.Context, key string, v interface{}, d time.Duration) error {
+	b, err := marshal(v)
+	if err != nil {
 		return ErrNotJSONMarshalable
 	}
 
-	// _, err := s.instance.PutObject(&s3.PutObjectInput{
-	// 	Key:     aws.String(relativePath(key)),
-	// 	Body:    bytes.NewReader(buf.Bytes()),
-	// 	Bucket:  aws.String(s.bucket),
-	// 	Tagging: aws.String("go-cache"),
-	// })
-	return nil
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(key)),
+		Body:   bytes.NewReader(b),
+	}
+	if d > 0 {
+		expires := strconv.FormatInt(time.Now().Add(d).UnixNano(), 10)
+		input.Metadata = map[string]*string{s3ExpiresMeta: aws.String(expires)}
+	}
+
+	_, err = s.instance.PutObjectWithContext(ctx, input)
+	return err
 }
 
 func (s s3storage) Read(key string) (interface{}, error) {
-	out, err := s.instance.GetObject(&s3.GetObjectInput{
-		// Bucket: aws.String(s.bucket),
-		// Key:    aws.String(relativePath(key)),
-	})
+	return s.ReadContext(context.Background(), key)
+}
 
+func (s s3storage) ReadContext(ctx context.Context, key string) (interface{}, error) {
+	out, err := s.instance.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(key)),
+	})
 	if err != nil {
+		if isNotFound(err) {
+			return nil, ErrKeyNotExist
+		}
 		return nil, err
 	}
+	defer out.Body.Close()
+
+	if expires, ok := metadata(out.Metadata, s3ExpiresMeta); ok {
+		if n, err := strconv.ParseInt(expires, 10, 64); err == nil && n <= time.Now().UnixNano() {
+			s.DeleteContext(ctx, key)
+			return nil, ErrKeyNotExist
+		}
+	}
 
 	return ioutil.ReadAll(out.Body)
 }
 
 func (s s3storage) Delete(key string) error {
-	// _, err := s.instance.DeleteObject(&s3.DeleteObjectInput{
-	// 	Bucket: aws.String(s.bucket),
-	// 	Key:    aws.String(relativePath(key)),
-	// })
-	return nil
+	return s.DeleteContext(context.Background(), key)
 }
 
+func (s s3storage) DeleteContext(ctx context.Context, key string) error {
+	_, err := s.instance.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(key)),
+	})
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (s s3storage) StoresBytes() {}
+
 func (s s3storage) Flush() error {
-	return errors.New("S3 does not support flush")
+	return s.FlushContext(context.Background())
+}
+
+// FlushContext deletes all the objects under the key prefix, a page of
+// up to 1000 objects at a time. The first page is listed again after
+// every deletion rather than paginating over a changing listing
+func (s s3storage) FlushContext(ctx context.Context) error {
+	for {
+		page, err := s.instance.ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{
+			Bucket: aws.String(s.bucket),
+			Prefix: aws.String(s.prefix),
+		})
+		if err != nil {
+			return err
+		}
+		if len(page.Contents) == 0 {
+			return nil
+		}
+
+		objects := make([]*s3.ObjectIdentifier, len(page.Contents))
+		for i, object := range page.Contents {
+			objects[i] = &s3.ObjectIdentifier{Key: object.Key}
+		}
+
+		out, err := s.instance.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+		if len(out.Errors) > 0 {
+			return fmt.Errorf("deleting %s: %s", aws.StringValue(out.Errors[0].Key), aws.StringValue(out.Errors[0].Message))
+		}
+	}
+}
+
+func (s s3storage) key(key string) string {
+	return s.prefix + key
+}
+
+func isNotFound(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case s3.ErrCodeNoSuchKey, "NotFound":
+			return true
+		}
+	}
+	return false
+}
+
+// metadata looks up object metadata regardless of how the name has been
+// canonicalized
+func metadata(m map[string]*string, name string) (string, bool) {
+	for k, v := range m {
+		if strings.EqualFold(k, name) && v != nil {
+			return *v, true
+		}
+	}
+	return "", false
 }
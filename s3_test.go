@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"gotest.tools/assert"
+)
+
+// fakeS3 starts an in process S3 server with the bucket "cache"
+func fakeS3(t *testing.T) *session.Session {
+	server := httptest.NewServer(gofakes3.New(s3mem.New()).Server())
+	t.Cleanup(server.Close)
+
+	sess, err := session.NewSession(&aws.Config{
+		Credentials:      credentials.NewStaticCredentials("key", "secret", ""),
+		Endpoint:         aws.String(server.URL),
+		Region:           aws.String("us-east-1"),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	assert.NilError(t, err)
+
+	_, err = s3.New(sess).CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("cache")})
+	assert.NilError(t, err)
+	return sess
+}
+
+func TestS3_ReadWrite(t *testing.T) {
+	s := NewS3(fakeS3(t), "cache", WithKeyPrefix("app/"))
+
+	assert.NilError(t, s.Write("key1", []byte("val1"), 0))
+	assert.NilError(t, s.Write("key2", map[string]int{"a": 1}, time.Minute))
+
+	v, err := s.Read("key1")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []byte("val1"), v)
+
+	v, err = s.Read("key2")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []byte(`{"a":1}`), v)
+
+	assert.NilError(t, s.Delete("key1"))
+	_, err = s.Read("key1")
+	assert.ErrorType(t, err, ErrKeyNotExist)
+	assert.NilError(t, s.Delete("key1"))
+}
+
+func TestS3_Expire(t *testing.T) {
+	sess := fakeS3(t)
+	s := NewS3(sess, "cache")
+
+	assert.NilError(t, s.Write("key1", []byte("val1"), 50*time.Millisecond))
+	_, err := s.Read("key1")
+	assert.NilError(t, err)
+
+	time.Sleep(60 * time.Millisecond)
+	_, err = s.Read("key1")
+	assert.ErrorType(t, err, ErrKeyNotExist)
+
+	// expired objects are deleted when read
+	_, err = s3.New(sess).HeadObject(&s3.HeadObjectInput{Bucket: aws.String("cache"), Key: aws.String("key1")})
+	assert.Assert(t, err != nil)
+}
+
+func TestS3_Flush(t *testing.T) {
+	var (
+		sess   = fakeS3(t)
+		s      = NewS3(sess, "cache", WithKeyPrefix("app/"))
+		others = NewS3(sess, "cache", WithKeyPrefix("other/"))
+	)
+
+	// more than a page of objects
+	for i := 0; i < 1100; i++ {
+		assert.NilError(t, s.Write(fmt.Sprintf("key%d", i), []byte("val"), 0))
+	}
+	assert.NilError(t, others.Write("key1", []byte("val"), 0))
+
+	assert.NilError(t, s.Flush())
+
+	out, err := s3.New(sess).ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String("cache")})
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(out.Contents))
+	assert.Equal(t, "other/key1", aws.StringValue(out.Contents[0].Key))
+}
+
+func TestS3_Cache(t *testing.T) {
+	c := New(WithStorage(NewS3(fakeS3(t), "cache", WithKeyPrefix("app/"))))
+
+	assert.NilError(t, c.Set("key1", User{City: "Yerevan"}, time.Minute, "tag1"))
+	assert.NilError(t, c.Set("key2", User{City: "Gyumri"}, 0, "tag1"))
+
+	var u User
+	assert.NilError(t, c.Get("key1", &u))
+	assert.Equal(t, User{City: "Yerevan"}, u)
+
+	var users []User
+	assert.NilError(t, c.ByTag("tag1", &users))
+	assert.DeepEqual(t, []User{{City: "Yerevan"}, {City: "Gyumri"}}, users)
+
+	assert.NilError(t, c.DelByTag("tag1"))
+	assert.ErrorContains(t, c.Get("key2", &u), ErrKeyNotExist.Error())
+}
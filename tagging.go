@@ -4,24 +4,27 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
-	"strings"
 
-	"github.com/sirupsen/logrus"
 	"github.com/spf13/cast"
 )
 
 type std struct {
 	ns     string
-	logger *logrus.Logger
+	logger Logger
 }
 
-func newStdTagger(logger *logrus.Logger, ns string) Tagger {
+func newStdTagger(logger Logger, ns string) Tagger {
 	return std{
 		logger: logger,
 		ns:     ns,
 	}
 }
 
+func (std std) withLogger(logger Logger) Tagger {
+	std.logger = logger
+	return std
+}
+
 func (std std) nsKey(key string) string {
 	return fmt.Sprintf("%s:%s", std.ns, key)
 }
@@ -41,12 +44,12 @@ func (std std) UnTag(s Storage, key string, tags ...string) error {
 		tags, _ = std.Tags(s, key)
 	}
 
-	std.logger.Debugf("Un-tag `%s` tags from `%s`", strings.Join(tags, ", "), key)
+	std.logger.Debug("Un-tag key", "key", key, "tags", tags)
 	if err := std.removeTagsFromKey(s, "key:"+key+":tags", tags...); err != nil {
 		return err
 	}
 
-	std.logger.Debugf("Remove `%s` tags from `%s` key", strings.Join(tags, ", "), key)
+	std.logger.Debug("Remove key from tags", "key", key, "tags", tags)
 	for i := range tags {
 		std.removeKeysFromTag(s, "tag:"+tags[i]+":keys", key)
 	}
@@ -72,6 +75,23 @@ func (std std) Keys(s Storage, tag string) ([]string, error) {
 	return slice(v), nil
 }
 
+// deleteTagged deletes the keys tagged with tag one by one
+func (std std) deleteTagged(s Storage, prefix, tag string) ([]string, error) {
+	keys, err := std.Keys(s, tag)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if err := s.Delete(prefix + key); err != nil {
+			return nil, err
+		}
+		if err := std.UnTag(s, key); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
 func (std std) addTagsToKey(s Storage, key string, tags ...string) error {
 	// get tags for the current key
 	v, err := s.Read(std.nsKey(key))
@@ -187,17 +207,14 @@ func insertIntoSorted(slice []string, val string) []string {
 		return slice
 	}
 
-	if index < len(slice)-1 && slice[index+1] == val {
-		return slice
-	}
-
 	if index == len(slice) {
 		return append(slice, val)
 	}
 
-	newslice := slice[:index]
+	newslice := make([]string, 0, len(slice)+1)
+	newslice = append(newslice, slice[:index]...)
 	newslice = append(newslice, val)
-	newslice = append(newslice, slice[index+1:]...)
+	newslice = append(newslice, slice[index:]...)
 	return newslice
 }
 
@@ -1 +1,15 @@
 package cache
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func Test_insertIntoSorted(t *testing.T) {
+	var slice []string
+	for _, val := range []string{"key3", "key1", "key4", "key2", "key1", "key0"} {
+		slice = insertIntoSorted(slice, val)
+	}
+	assert.DeepEqual(t, []string{"key0", "key1", "key2", "key3", "key4"}, slice)
+}
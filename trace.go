@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName is the name of the tracer of the cache, which is the
+// import path of the package
+const tracerName = "github.com/apzuk3/go-cache"
+
+// startSpan starts a span of the operation op on the given keys and
+// tags. It returns a no-op span unless tracing is configured
+func (c *Cache) startSpan(ctx context.Context, op string, keys, tags []string) (context.Context, trace.Span) {
+	if c.tracer == nil {
+		return ctx, noop.Span{}
+	}
+
+	attrs := make([]attribute.KeyValue, 0, 2)
+	switch len(keys) {
+	case 0:
+	case 1:
+		attrs = append(attrs, attribute.String("cache.key", c.spanKey(keys[0])))
+	default:
+		spanKeys := make([]string, len(keys))
+		for i := range keys {
+			spanKeys[i] = c.spanKey(keys[i])
+		}
+		attrs = append(attrs, attribute.StringSlice("cache.keys", spanKeys))
+	}
+	if len(tags) > 0 {
+		attrs = append(attrs, attribute.StringSlice("cache.tags", tags))
+	}
+	return c.tracer.Start(ctx, "cache."+op, trace.WithAttributes(attrs...))
+}
+
+// spanKey returns the key as recorded in spans, hashed if configured
+func (c *Cache) spanKey(key string) string {
+	if !c.hashSpanKeys {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// visit calls fn with s in a child span of the storage if tracing is
+// configured
+func (c *Cache) visit(ctx context.Context, p Priority, s Storage, fn func(ctx context.Context, s Storage) (bool, error)) (bool, error) {
+	if c.tracer == nil {
+		return fn(ctx, s)
+	}
+
+	name := storageName(s)
+	ctx, span := c.tracer.Start(ctx, "cache."+name, trace.WithAttributes(
+		attribute.String("cache.storage", name),
+		attribute.String("cache.tier", p.String()),
+	))
+	terminate, err := fn(ctx, s)
+	endSpan(span, err)
+	return terminate, err
+}
+
+// annotate adds attributes to the span in ctx if tracing is configured,
+// which may be the span of a storage visited
+func (c *Cache) annotate(ctx context.Context, attrs ...attribute.KeyValue) {
+	if c.tracer != nil {
+		trace.SpanFromContext(ctx).SetAttributes(attrs...)
+	}
+}
+
+// endSpan ends the span, recording err unless keys are merely missing
+func endSpan(span trace.Span, err error) {
+	if err != nil && !missing(err) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gotest.tools/assert"
+)
+
+func tracing(t *testing.T) (*tracetest.InMemoryExporter, Option) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { tp.Shutdown(context.Background()) })
+	return exporter, WithTracerProvider(tp)
+}
+
+func attr(s tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range s.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestCache_Tracing(t *testing.T) {
+	exporter, option := tracing(t)
+	high, medium := InMemory(), InMemory()
+	c := New(WithHighPriorityStorage(high), WithMediumPriorityStorage(medium), option)
+
+	assert.NilError(t, c.Set("key1", "abc", 0, "tag1"))
+	spans := exporter.GetSpans()
+	assert.Equal(t, 3, len(spans))
+	set := spans[2]
+	assert.Equal(t, "cache.Set", set.Name)
+	assert.Equal(t, "key1", attr(set, "cache.key").AsString())
+	assert.DeepEqual(t, []string{"tag1"}, attr(set, "cache.tags").AsStringSlice())
+	for i, tier := range []string{"high", "medium"} {
+		assert.Equal(t, "cache.inmem", spans[i].Name)
+		assert.Equal(t, set.SpanContext.SpanID(), spans[i].Parent.SpanID())
+		assert.Equal(t, tier, attr(spans[i], "cache.tier").AsString())
+	}
+
+	// read from the medium storage and propagated to the high one
+	assert.NilError(t, high.Delete("go:cache:key1"))
+	exporter.Reset()
+	var v string
+	assert.NilError(t, c.Get("key1", &v))
+	spans = exporter.GetSpans()
+	assert.Equal(t, 3, len(spans))
+	get := spans[2]
+	assert.Equal(t, "cache.Get", get.Name)
+	assert.Equal(t, true, attr(get, "cache.hit").AsBool())
+	assert.Equal(t, "medium", attr(get, "cache.tier").AsString())
+	assert.Equal(t, codes.Unset, get.Status.Code)
+	assert.Equal(t, false, attr(spans[0], "cache.hit").AsBool())
+	assert.Equal(t, true, attr(spans[1], "cache.hit").AsBool())
+
+	exporter.Reset()
+	assert.ErrorContains(t, c.Get("key2", &v), ErrKeyNotExist.Error())
+	get = exporter.GetSpans()[2]
+	assert.Equal(t, false, attr(get, "cache.hit").AsBool())
+	assert.Equal(t, codes.Unset, get.Status.Code)
+
+	exporter.Reset()
+	assert.NilError(t, c.ByTag("tag1", &v))
+	spans = exporter.GetSpans()
+	assert.Equal(t, "cache.ByTag", spans[len(spans)-1].Name)
+	assert.DeepEqual(t, []string{"tag1"}, attr(spans[len(spans)-1], "cache.tags").AsStringSlice())
+
+	exporter.Reset()
+	assert.NilError(t, c.DelByTag("tag1"))
+	spans = exporter.GetSpans()
+	delByTag := spans[len(spans)-1]
+	assert.Equal(t, "cache.DelByTag", delByTag.Name)
+	var dels int
+	for _, s := range spans {
+		if s.Name == "cache.Del" {
+			dels++
+			assert.Equal(t, "key1", attr(s, "cache.key").AsString())
+			assert.Equal(t, delByTag.SpanContext.TraceID(), s.SpanContext.TraceID())
+		}
+	}
+	assert.Equal(t, 1, dels)
+}
+
+func TestCache_TracingError(t *testing.T) {
+	exporter, option := tracing(t)
+	c := New(WithStorage(&mock{err: errors.New("some error")}), option)
+
+	assert.Assert(t, c.Del("key1", "key2") != nil)
+	spans := exporter.GetSpans()
+	assert.Equal(t, 2, len(spans))
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, codes.Error, spans[1].Status.Code)
+	assert.DeepEqual(t, []string{"key1", "key2"}, attr(spans[1], "cache.keys").AsStringSlice())
+}
+
+func TestCache_TracingHashedKeys(t *testing.T) {
+	exporter, option := tracing(t)
+	c := New(WithStorage(InMemory()), option, WithHashedSpanKeys())
+
+	assert.NilError(t, c.Set("user:1", 1, 0))
+	spans := exporter.GetSpans()
+	assert.Equal(t, "abc3a47b8ad18b855c687d9ca2c6091ee7312db5563021942a57ada889c87b34", attr(spans[len(spans)-1], "cache.key").AsString())
+}
@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Typed wraps a Cache to store and read values of type T only. Values
+// are decoded straight into T with a Codec instead of being mapped
+// over with mapstructure, so types like time.Time survive the round
+// trip and type mismatches are reported as errors
+type Typed[T any] struct {
+	c     *Cache
+	codec Codec
+}
+
+// NewTyped creates a new typed wrapper around the given cache
+func NewTyped[T any](c *Cache) *Typed[T] {
+	return &Typed[T]{c: c, codec: JSON}
+}
+
+// Set stores the value the same way Cache.Set does
+func (t *Typed[T]) Set(key string, v T, expiration time.Duration, tags ...string) error {
+	return t.c.set(context.Background(), key, v, expiration, tags...)
+}
+
+// SetContext is like Set but carries ctx down to the storage
+func (t *Typed[T]) SetContext(ctx context.Context, key string, v T, expiration time.Duration, tags ...string) error {
+	return t.c.set(ctx, key, v, expiration, tags...)
+}
+
+// Get reads the value for the given key
+func (t *Typed[T]) Get(key string) (T, error) {
+	return t.GetContext(context.Background(), key)
+}
+
+// GetContext is like Get but carries ctx down to the storage
+func (t *Typed[T]) GetContext(ctx context.Context, key string) (T, error) {
+	var out T
+	_, _, err := t.c.get(ctx, key, func(v interface{}) error {
+		return t.decode(v, &out)
+	})
+	return out, err
+}
+
+// GetOrLoad reads the value for the given key or loads it the same way
+// Cache.GetOrLoad does
+func (t *Typed[T]) GetOrLoad(key string, loader func() (T, error), expiration time.Duration, tags ...string) (T, error) {
+	return t.GetOrLoadContext(context.Background(), key, loader, expiration, tags...)
+}
+
+// GetOrLoadContext is like GetOrLoad but carries ctx down to the storage
+func (t *Typed[T]) GetOrLoadContext(ctx context.Context, key string, loader func() (T, error), expiration time.Duration, tags ...string) (T, error) {
+	var out T
+	err := t.c.getOrLoad(ctx, key, func(v interface{}) error {
+		return t.decode(v, &out)
+	}, func() (interface{}, error) {
+		return loader()
+	}, expiration, tags...)
+	return out, err
+}
+
+// GetMulti reads the values for the given keys. Keys which do not
+// exist are left out of the result
+func (t *Typed[T]) GetMulti(keys ...string) (map[string]T, error) {
+	return t.GetMultiContext(context.Background(), keys...)
+}
+
+// GetMultiContext is like GetMulti but carries ctx down to the storage
+func (t *Typed[T]) GetMultiContext(ctx context.Context, keys ...string) (map[string]T, error) {
+	var out map[string]T
+	err := t.c.getMulti(ctx, keys, func(v interface{}) error {
+		values := v.(map[string]interface{})
+		out = make(map[string]T, len(values))
+		for key := range values {
+			var val T
+			if err := t.decode(values[key], &val); err != nil {
+				return err
+			}
+			out[key] = val
+		}
+		return nil
+	})
+	return out, err
+}
+
+// SetMulti stores all the given values the same way Cache.SetMulti does
+func (t *Typed[T]) SetMulti(values map[string]T, expiration time.Duration, tags ...string) error {
+	return t.SetMultiContext(context.Background(), values, expiration, tags...)
+}
+
+// SetMultiContext is like SetMulti but carries ctx down to the storage
+func (t *Typed[T]) SetMultiContext(ctx context.Context, values map[string]T, expiration time.Duration, tags ...string) error {
+	m := make(map[string]interface{}, len(values))
+	for key, v := range values {
+		m[key] = v
+	}
+	return t.c.setMulti(ctx, m, expiration, tags...)
+}
+
+// ByTag reads all the values tagged with the given tag
+func (t *Typed[T]) ByTag(tag string) ([]T, error) {
+	return t.ByTagContext(context.Background(), tag)
+}
+
+// ByTagContext is like ByTag but carries ctx down to the storage
+func (t *Typed[T]) ByTagContext(ctx context.Context, tag string) ([]T, error) {
+	var out []T
+	err := t.c.byTag(ctx, tag, func(v interface{}) error {
+		values := v.([]interface{})
+		out = make([]T, len(values))
+		for i := range values {
+			if err := t.decode(values[i], &out[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return out, err
+}
+
+// decode decodes v into out. Values which already are of type T are
+// assigned as they are, encoded ones are unmarshaled with the codec they
+// have been encoded with and anything else is passed through the codec
+func (t *Typed[T]) decode(v interface{}, out *T) error {
+	if x, ok := v.(T); ok {
+		*out = x
+		return nil
+	}
+	if x, ok := v.(encoded); ok {
+		return x.codec.Unmarshal(x.data, out)
+	}
+
+	b, err := t.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return t.codec.Unmarshal(b, out)
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestTyped_Get(t *testing.T) {
+	c := NewTyped[User](New(WithStorage(InMemory())))
+	assert.NilError(t, c.Set("key1", User{City: "Yerevan", Email: "user@example.com"}, 0))
+
+	u, err := c.Get("key1")
+	assert.NilError(t, err)
+	assert.Equal(t, User{City: "Yerevan", Email: "user@example.com"}, u)
+}
+
+func TestTyped_GetTime(t *testing.T) {
+	fs := Filesystem(t.TempDir())
+	c := NewTyped[time.Time](New(WithStorage(fs)))
+
+	now := time.Now().UTC().Truncate(time.Second)
+	assert.NilError(t, c.Set("key1", now, 0))
+
+	v, err := c.Get("key1")
+	assert.NilError(t, err)
+	assert.Assert(t, now.Equal(v))
+}
+
+func TestTyped_GetMismatch(t *testing.T) {
+	cache := New(WithStorage(Filesystem(t.TempDir())))
+	assert.NilError(t, cache.Set("key1", "abc", 0))
+
+	_, err := NewTyped[int](cache).Get("key1")
+	assert.Assert(t, err != nil)
+}
+
+func TestTyped_GetNotFound(t *testing.T) {
+	c := NewTyped[int](New(WithStorage(InMemory())))
+
+	v, err := c.Get("key1")
+	assert.ErrorContains(t, err, ErrKeyNotExist.Error())
+	assert.Equal(t, 0, v)
+}
+
+func TestTyped_ByTag(t *testing.T) {
+	c := NewTyped[User](New(WithStorage(Filesystem(t.TempDir()))))
+	c.Set("key1", User{City: "Yerevan"}, 0, "tag1")
+	c.Set("key2", User{City: "Gyumri"}, 0, "tag1", "tag2")
+	c.Set("key3", User{City: "Vanadzor"}, 0, "tag2")
+
+	users, err := c.ByTag("tag1")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []User{{City: "Yerevan"}, {City: "Gyumri"}}, users)
+}
+
+func TestTyped_GetOrLoad(t *testing.T) {
+	c := NewTyped[User](New(WithStorage(InMemory())))
+
+	u, err := c.GetOrLoad("key1", func() (User, error) {
+		return User{City: "Yerevan"}, nil
+	}, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, User{City: "Yerevan"}, u)
+
+	u, err = c.Get("key1")
+	assert.NilError(t, err)
+	assert.Equal(t, User{City: "Yerevan"}, u)
+}
+
+func TestTyped_GetMulti(t *testing.T) {
+	c := NewTyped[User](New(WithStorage(InMemory())))
+	assert.NilError(t, c.SetMulti(map[string]User{
+		"key1": {City: "Yerevan"},
+		"key2": {City: "Gyumri"},
+	}, 0))
+
+	users, err := c.GetMulti("key1", "key2", "key3")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]User{"key1": {City: "Yerevan"}, "key2": {City: "Gyumri"}}, users)
+}
@@ -1,6 +1,9 @@
 package cache
 
 import (
+	"context"
+	"math"
+	"math/rand"
 	"time"
 )
 
@@ -10,11 +13,38 @@ import (
 // Any error occurred for medium level storage will be ignored
 type Priority int
 
+// String returns the name of the priority, e.g. high
+func (p Priority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityMedium:
+		return "medium"
+	}
+	return "unknown"
+}
+
 type item struct {
 	Key     string        `json:"key"`
 	Val     interface{}   `json:"val"`
 	Created time.Time     `json:"created"`
 	Expires time.Duration `json:"expires"`
+
+	// Tags are the versions of the item's tags at the time it has
+	// been written, used by a TagVersioner
+	Tags map[string]uint64 `json:"tags,omitempty"`
+
+	// StaleWhileRevalidate and StaleIfError are how long the item may be
+	// served once expired while it is reloaded and when reloading fails
+	StaleWhileRevalidate time.Duration `json:"swr,omitempty"`
+	StaleIfError         time.Duration `json:"sie,omitempty"`
+
+	// Delta is how long loading the value has taken
+	Delta time.Duration `json:"delta,omitempty"`
+
+	// raw is set for counters, which are stored as plain integers rather
+	// than items and are only read from the storage they're updated in
+	raw bool
 }
 
 func (i item) expired() bool {
@@ -24,6 +54,84 @@ func (i item) expired() bool {
 	return i.Created.Add(i.Expires).Before(time.Now())
 }
 
+// expiresEarly reports whether the item is to be treated as expired
+// already, which gets more likely as it gets closer to expiring and the
+// longer it has taken to load as described by the XFetch algorithm.
+// Greater betas favour expiring earlier
+func (i item) expiresEarly(beta float64) bool {
+	if i.Expires == 0 || i.Delta == 0 {
+		return false
+	}
+	early := float64(i.Delta) * beta * -math.Log(1-rand.Float64())
+	return early >= float64(-i.staleFor())
+}
+
+// staleFor returns how long the item has been expired for, which is
+// negative while it hasn't
+func (i item) staleFor() time.Duration {
+	return time.Since(i.Created.Add(i.Expires))
+}
+
+// grace returns how long the item may be served once expired at most
+func (i item) grace() time.Duration {
+	if i.StaleIfError > i.StaleWhileRevalidate {
+		return i.StaleIfError
+	}
+	return i.StaleWhileRevalidate
+}
+
+// servable reports whether the item may be returned by a read, i.e. it
+// hasn't expired or may be served stale while it's revalidated
+func (i item) servable() bool {
+	return !i.expired() || i.staleFor() <= i.StaleWhileRevalidate
+}
+
+// dead reports whether the item can't be served anymore, even stale
+func (i item) dead() bool {
+	return i.Expires != 0 && i.staleFor() > i.grace()
+}
+
+// ttl returns how long the item is to be kept by a storage, zero meaning
+// forever
+func (i item) ttl() time.Duration {
+	if i.Expires == 0 {
+		return 0
+	}
+	if left := i.grace() - i.staleFor(); left > 0 {
+		return left
+	}
+	// dead by now, which is not forever
+	return time.Nanosecond
+}
+
+// outdated reports whether any of the tags of the item has another
+// version than the one recorded in the item
+func (i item) outdated(versions map[string]uint64) bool {
+	if versions == nil {
+		return false
+	}
+	for tag, version := range i.Tags {
+		if versions[tag] != version {
+			return true
+		}
+	}
+	return false
+}
+
+// withVersions returns a copy of the item recording the given versions
+// of its tags
+func (i item) withVersions(versions map[string]uint64) item {
+	if versions == nil || len(i.Tags) == 0 {
+		return i
+	}
+	tags := make(map[string]uint64, len(i.Tags))
+	for tag := range i.Tags {
+		tags[tag] = versions[tag]
+	}
+	i.Tags = tags
+	return i
+}
+
 // Storage is an interface to write, read, delete and empty
 // a data storage. Any struct implementing Storage interface
 // can be passed to cache.New(WithStorage(...)) to use as taggable
@@ -42,6 +150,49 @@ type Storage interface {
 	Flush() error
 }
 
+// ContextStorage is an optional interface of a Storage which is able to
+// carry a context.Context down to the backend. Cache detects it alongside
+// Storage and prefers it whenever a context is passed to the *Context
+// methods
+type ContextStorage interface {
+	// WriteContext writes to the storage
+	WriteContext(ctx context.Context, key string, v interface{}, ttl time.Duration) error
+
+	// ReadContext reads from the storage for the key
+	ReadContext(ctx context.Context, key string) (interface{}, error)
+
+	// DeleteContext deletes from the storage
+	DeleteContext(ctx context.Context, key string) error
+
+	// FlushContext flushes cache storage
+	FlushContext(ctx context.Context) error
+}
+
+// ByteStorage is an optional interface of a Storage which persists
+// values as bytes. Cache encodes the items it writes to such storage
+// with its Codec instead of handing the item over as it is
+type ByteStorage interface {
+	Storage
+
+	// StoresBytes is a marker method
+	StoresBytes()
+}
+
+// BatchStorage is an optional interface of a Storage which is able to
+// read, write and delete several keys in a single round trip. Cache
+// falls back to per key calls for storage not implementing it
+type BatchStorage interface {
+	// ReadMulti reads the given keys. Missing keys are left out
+	// of the result
+	ReadMulti(ctx context.Context, keys ...string) (map[string]interface{}, error)
+
+	// WriteMulti writes all the given key-value pairs
+	WriteMulti(ctx context.Context, values map[string]interface{}, ttl time.Duration) error
+
+	// DeleteMulti deletes the given keys
+	DeleteMulti(ctx context.Context, keys ...string) error
+}
+
 // Tagger ins an interface to tag and untag data with the given tags
 // Any struct implemening tagger interface can be passed to
 // cache.New(WithTagger(...)) to use a data tagger
@@ -58,3 +209,69 @@ type Tagger interface {
 	// receive all tag's keys
 	Keys(s Storage, tag string) ([]string, error)
 }
+
+// TagDeleter is an optional interface of a Tagger which is able to delete
+// the keys tagged with a tag from a storage on its own, e.g. in a single
+// round trip. Cache removes the deleted keys from the other storage
+type TagDeleter interface {
+	// DeleteTagged deletes the keys tagged with tag, prefixed with
+	// prefix in the storage, untags them and returns them
+	DeleteTagged(s Storage, prefix, tag string) ([]string, error)
+}
+
+// TagVersioner is an optional interface of a Tagger which invalidates
+// tags by incrementing their versions. Cache records the versions of the
+// tags of an item in the item and treats it as missing once they change
+type TagVersioner interface {
+	// Versions returns the current versions of the given tags
+	Versions(s Storage, tags ...string) (map[string]uint64, error)
+
+	// Invalidate increments the versions of the given tags
+	Invalidate(s Storage, tags ...string) error
+}
+
+// CASStorage is an optional interface of a Storage supporting
+// compare-and-swap. Versions are opaque and only meaningful to the
+// storage they have been read from
+type CASStorage interface {
+	// ReadVersion reads from the storage for the key along with the
+	// version of the value
+	ReadVersion(ctx context.Context, key string) (interface{}, uint64, error)
+
+	// CompareAndWrite writes to the storage if the value still has the
+	// given version, failing with ErrVersionMismatch otherwise
+	CompareAndWrite(ctx context.Context, key string, version uint64, v interface{}, ttl time.Duration) error
+}
+
+// CounterStorage is an optional interface of a Storage able to update
+// integer counters atomically
+type CounterStorage interface {
+	// Incr adds delta to the counter at key and returns its new value.
+	// A missing counter is created with the given ttl, an existing one
+	// keeps its own. ErrNotCounter is returned if the key holds anything
+	// but an integer
+	Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+}
+
+// ConditionalStorage is an optional interface of a Storage able to write
+// a key depending on whether it exists, atomically
+type ConditionalStorage interface {
+	// Add writes to the storage unless the key exists, failing with
+	// ErrKeyExists otherwise
+	Add(ctx context.Context, key string, v interface{}, ttl time.Duration) error
+
+	// Replace writes to the storage if the key exists, failing with
+	// ErrKeyNotExist otherwise
+	Replace(ctx context.Context, key string, v interface{}, ttl time.Duration) error
+}
+
+// LockStorage is the interface of a Storage a Locker can hold locks in
+type LockStorage interface {
+	Storage
+	ConditionalStorage
+	CASStorage
+
+	// CompareAndDelete deletes from the storage if the value still has
+	// the given version, failing with ErrVersionMismatch otherwise
+	CompareAndDelete(ctx context.Context, key string, version uint64) error
+}
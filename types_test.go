@@ -1 +1,26 @@
 package cache
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestItem_ExpiresEarly(t *testing.T) {
+	var (
+		now  = time.Now()
+		far  = item{Created: now, Expires: time.Hour, Delta: time.Second}
+		near = item{Created: now.Add(-time.Hour), Expires: time.Hour + 500*time.Millisecond, Delta: time.Second}
+	)
+
+	var early int
+	for i := 0; i < 1000; i++ {
+		assert.Assert(t, !far.expiresEarly(1))
+		if near.expiresEarly(1) {
+			early++
+		}
+	}
+	// about exp(-0.5) of the callers expire an item due in delta/2
+	assert.Assert(t, early > 500 && early < 700, early)
+}
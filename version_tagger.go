@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrNotSupported indicates that the operation is not supported by the
+// storage or tagger in use
+var ErrNotSupported = errors.New("operation not supported")
+
+// versionTagger keeps a version counter per tag instead of tag indexes.
+// Items carry the versions their tags had when written and become stale
+// once any of these is incremented
+type versionTagger struct {
+	ns string
+}
+
+// VersionTagger creates a Tagger invalidating tags by incrementing a
+// version counter per tag, which can be passed to
+// cache.New(WithTagger(...)). DelByTag is a single increment per tag and
+// storage, items written with older versions of their tags are treated
+// as missing. Tagged keys can't be enumerated, so ByTag is not supported
+func VersionTagger(ns string) Tagger {
+	return versionTagger{ns: ns}
+}
+
+func (t versionTagger) key(tag string) string {
+	return t.ns + ":tag:" + tag + ":version"
+}
+
+// Tag does nothing as the tags are stored along with the item
+func (t versionTagger) Tag(s Storage, key string, tags ...string) error { return nil }
+
+// UnTag does nothing as the tags are stored along with the item
+func (t versionTagger) UnTag(s Storage, key string, tags ...string) error { return nil }
+
+// Tags returns no tags as the tags are stored along with the item
+func (t versionTagger) Tags(s Storage, key string) ([]string, error) { return nil, nil }
+
+// Keys is not supported
+func (t versionTagger) Keys(s Storage, tag string) ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+// Versions returns the current versions of the tags, starting the
+// versions of unknown tags. Versions start at the current time so that
+// a version lost, e.g. evicted by memcached, never starts over at a
+// value items may have been written with
+func (t versionTagger) Versions(s Storage, tags ...string) (map[string]uint64, error) {
+	keys := make([]string, len(tags))
+	for i, tag := range tags {
+		keys[i] = t.key(tag)
+	}
+
+	values, err := readMulti(context.Background(), s, keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string]uint64, len(tags))
+	for i, tag := range tags {
+		if version, ok := counter(values[keys[i]]); ok {
+			versions[tag] = uint64(version)
+			continue
+		}
+
+		v, err := add(s, keys[i], []byte(strconv.FormatUint(uint64(time.Now().UnixNano()), 10)))
+		if err != nil {
+			return nil, err
+		}
+		version, ok := counter(v)
+		if !ok {
+			return nil, ErrNotCounter
+		}
+		versions[tag] = uint64(version)
+	}
+	return versions, nil
+}
+
+// add writes v for key unless it exists and returns the value of key,
+// which is the one written by another writer if it has won the race.
+// It's only atomic if the storage implements ConditionalStorage
+func add(s Storage, key string, v interface{}) (interface{}, error) {
+	ctx := context.Background()
+	if b, ok := s.(bound); ok {
+		ctx, s = b.ctx, b.s
+	}
+	cs, ok := s.(ConditionalStorage)
+	if !ok {
+		return v, write(ctx, s, key, v, 0)
+	}
+
+	err := cs.Add(ctx, key, v, 0)
+	if err == ErrKeyExists {
+		return read(ctx, s, key)
+	}
+	return v, err
+}
+
+// Invalidate increments the versions of the tags. Unknown versions are
+// started at 1, which items can't have been written with
+func (t versionTagger) Invalidate(s Storage, tags ...string) error {
+	for _, tag := range tags {
+		if _, err := incr(s, t.key(tag), 1, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
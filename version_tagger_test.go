@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	redisClient "github.com/redis/go-redis/v9"
+	"gotest.tools/assert"
+)
+
+func TestVersionTagger(t *testing.T) {
+	storages := map[string]func(t *testing.T) Storage{
+		"memcached": func(t *testing.T) Storage { return Memcached(memcached(t)) },
+		"redis": func(t *testing.T) Storage {
+			return Redis(&redisClient.Options{Addr: miniRedis(t).Addr()})
+		},
+		"in memory": func(t *testing.T) Storage { return InMemory() },
+	}
+
+	for name, storage := range storages {
+		t.Run(name, func(t *testing.T) {
+			c := New(WithStorage(storage(t)), WithTagger(VersionTagger("go:cache:tagger")))
+
+			assert.NilError(t, c.Set("key1", 1, 0, "tag1", "tag2"))
+			assert.NilError(t, c.Set("key2", 2, 0, "tag1"))
+			assert.NilError(t, c.SetMulti(map[string]interface{}{"key3": 3}, 0, "tag2"))
+
+			var v int
+			assert.NilError(t, c.Get("key1", &v))
+			assert.Equal(t, 1, v)
+
+			assert.NilError(t, c.DelByTag("tag2"))
+
+			assert.ErrorContains(t, c.Get("key1", &v), ErrKeyNotExist.Error())
+			assert.ErrorContains(t, c.Get("key3", &v), ErrKeyNotExist.Error())
+			assert.NilError(t, c.Get("key2", &v))
+			assert.Equal(t, 2, v)
+
+			var m map[string]int
+			assert.NilError(t, c.GetMulti([]string{"key1", "key2", "key3"}, &m))
+			assert.DeepEqual(t, map[string]int{"key2": 2}, m)
+
+			// rewritten items record the new versions
+			assert.NilError(t, c.Set("key1", 10, 0, "tag2"))
+			assert.NilError(t, c.Get("key1", &v))
+			assert.Equal(t, 10, v)
+
+			var ints []int
+			assert.ErrorContains(t, c.ByTag("tag1", &ints), ErrNotSupported.Error())
+		})
+	}
+}
+
+func TestVersionTagger_Propagate(t *testing.T) {
+	var (
+		high = InMemory()
+		low  = Memcached(memcached(t))
+	)
+	New(WithStorage(low), WithTagger(VersionTagger("go:cache:tagger"))).Set("key1", 1, time.Minute, "tag1")
+
+	c := New(
+		WithHighPriorityStorage(high),
+		WithMediumPriorityStorage(low),
+		WithTagger(VersionTagger("go:cache:tagger")),
+	)
+
+	var v int
+	assert.NilError(t, c.Get("key1", &v))
+	_, err := high.Read("go:cache:key1")
+	assert.NilError(t, err)
+
+	assert.NilError(t, c.DelByTag("tag1"))
+	assert.ErrorContains(t, c.Get("key1", &v), ErrKeyNotExist.Error())
+}
+
+// lagging misses the keys read with ReadMulti, as if they were written by
+// another writer right after
+type lagging struct {
+	*InMem
+}
+
+func (l lagging) ReadMulti(ctx context.Context, keys ...string) (map[string]interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func TestVersionTagger_VersionsRace(t *testing.T) {
+	var (
+		s      = InMemory()
+		tagger = VersionTagger("go:cache:tagger").(versionTagger)
+	)
+	assert.NilError(t, s.Write(tagger.key("tag1"), []byte("42"), 0))
+
+	versions, err := tagger.Versions(lagging{s}, "tag1")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]uint64{"tag1": 42}, versions)
+
+	v, err := s.Read(tagger.key("tag1"))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []byte("42"), v)
+}